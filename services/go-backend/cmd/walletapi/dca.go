@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+const (
+	dcaBatchSize       = 50
+	defaultDCAInterval = 30 * time.Second
+)
+
+// dcaFeeReserve is kept on top of the buy amount for DEX gas.
+var dcaFeeReserve = big.NewRat(3, 10) // 0.3 TON
+
+// dcaScheduler turns due DCA plans into ordinary queued swap orders. The
+// relayer executes them; the scheduler only reads their outcome back.
+type dcaScheduler struct {
+	store       *database.Store
+	ton         *ton.Client
+	interval    time.Duration
+	maxFailures int
+}
+
+func (d *dcaScheduler) run(ctx context.Context) {
+	interval := d.interval
+	if interval <= 0 {
+		interval = defaultDCAInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.tick(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("[dca] %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *dcaScheduler) tick(ctx context.Context) error {
+	if _, err := d.store.SettleDCAOrders(ctx, d.maxFailures); err != nil {
+		return err
+	}
+	plans, err := d.store.ListDueDCAPlans(ctx, dcaBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range plans {
+		plan := &plans[i]
+		funded, err := d.funded(ctx, plan)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			log.Printf("[dca] plan %d balance check: %v", plan.ID, err)
+			continue
+		}
+		if !funded {
+			if err := d.store.PauseDCAPlan(ctx, plan.ID, database.DCAPauseInsufficient); err != nil {
+				return err
+			}
+			log.Printf("[dca] plan %d paused: insufficient balance", plan.ID)
+			continue
+		}
+		order, err := d.store.RunDCAPlan(ctx, plan.ID)
+		if err != nil {
+			return err
+		}
+		if order != nil {
+			log.Printf("[dca] plan %d run %d/%d: order %d queued", plan.ID, plan.RunsDone+1, plan.TotalRuns, order.ID)
+		}
+	}
+	return nil
+}
+
+// funded reports whether the plan's wallet can pay for the next buy.
+func (d *dcaScheduler) funded(ctx context.Context, plan *database.DCAPlan) (bool, error) {
+	wallet, err := d.store.GetWalletByID(ctx, plan.WalletID)
+	if err != nil {
+		return false, err
+	}
+	if wallet == nil {
+		return false, nil
+	}
+	bal, err := d.ton.GetAccountBalance(ctx, wallet.Address)
+	if err != nil {
+		return false, err
+	}
+	balance, ok := new(big.Rat).SetString(strings.TrimSpace(bal.Ton))
+	if !ok {
+		return false, errors.New("invalid balance " + bal.Ton)
+	}
+	amount, ok := new(big.Rat).SetString(strings.TrimSpace(plan.TonAmount))
+	if !ok {
+		return false, errors.New("invalid ton_amount " + plan.TonAmount)
+	}
+	return balance.Cmp(amount.Add(amount, dcaFeeReserve)) >= 0, nil
+}
@@ -34,28 +34,59 @@ func main() {
 	}
 
 	tonClient := ton.NewClient(ton.Config{
-		Endpoint: cfg.TonEndpoint,
-		APIKey:   cfg.TonAPIKey,
-	})
-
-	srv := server.New(server.Options{
-		Config:    cfg,
-		Store:     store,
-		TonClient: tonClient,
+		Endpoints:        ton.ParseEndpoints(cfg.TonEndpoints, cfg.TonAPIKey),
+		Endpoint:         cfg.TonEndpoint,
+		APIKey:           cfg.TonAPIKey,
+		RateLimit:        cfg.TonRateLimit,
+		RateBurst:        cfg.TonRateBurst,
+		CacheTTL:         cfg.TonCacheTTL,
+		HighloadQueryIDs: store.NextHighloadQueryID,
 	})
 
 	var swapRelayer *relayer.SwapRelayer
 	if cfg.EnableGoRelayer && len(cfg.MasterKey) == 32 {
 		swapRelayer = relayer.New(relayer.Options{
 			Store:     store,
+			TonClient: tonClient,
 			Logger:    log.Default(),
 			MasterKey: cfg.MasterKey,
+			Stonfi: relayer.StonfiOptions{
+				V1Router: cfg.StonfiV1Router,
+				V1PTON:   cfg.StonfiV1PTON,
+				V2Router: cfg.StonfiV2Router,
+				V2PTON:   cfg.StonfiV2PTON,
+			},
+			Workers:            cfg.RelayerWorkers,
+			MaxAttempts:        cfg.RelayerAttempts,
+			DefaultSlippage:    cfg.DefaultSlippage,
+			LeaseDuration:      cfg.RelayerLease,
+			BatchSize:          cfg.RelayerBatchSize,
+			PriceCheckInterval: cfg.LimitCheckEvery,
 		})
 		swapRelayer.Start(ctx)
+
+		// DCA orders are only worth queueing when the relayer executes them.
+		dca := &dcaScheduler{
+			store:       store,
+			ton:         tonClient,
+			interval:    cfg.DCACheckEvery,
+			maxFailures: cfg.DCAMaxFailures,
+		}
+		go dca.run(ctx)
 	} else if cfg.EnableGoRelayer {
 		log.Println("ENABLE_GO_RELAYER set but MASTER_KEY_DEV missing or invalid length")
 	}
 
+	srvOpts := server.Options{
+		Config:    cfg,
+		Store:     store,
+		TonClient: tonClient,
+	}
+	if swapRelayer != nil {
+		srvOpts.Relayer = swapRelayer
+	}
+	srv := server.New(srvOpts)
+
 	if err := srv.Start(ctx); err != nil {
 		log.Fatalf("start HTTP server: %v", err)
 	}
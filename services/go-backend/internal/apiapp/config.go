@@ -35,6 +35,7 @@ func LoadConfig() (Config, error) {
 			APIKey: strings.TrimSpace(os.Getenv("TONCENTER_API_KEY")),
 		},
 	}
+	cfg.TonClient.Endpoints = ton.ParseEndpoints(os.Getenv("TON_RPC_ENDPOINTS"), cfg.TonClient.APIKey)
 	if cfg.TonClient.Endpoint == "" {
 		return cfg, fmt.Errorf("TON_RPC_ENDPOINT must be set")
 	}
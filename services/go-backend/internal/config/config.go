@@ -19,11 +19,28 @@ type Config struct {
 	DatabaseURL       string
 	MasterKey         []byte
 	TonEndpoint       string
+	TonEndpoints      string
 	TonAPIKey         string
+	TonRateLimit      float64
+	TonRateBurst      int
+	TonCacheTTL       time.Duration
 	DedustAPIBase     string
+	StonfiV1Router    string
+	StonfiV1PTON      string
+	StonfiV2Router    string
+	StonfiV2PTON      string
 	MaxWalletsPerUser int
+	WalletVersion     string
 	ShutdownTimeout   time.Duration
 	EnableGoRelayer   bool
+	LimitCheckEvery   time.Duration
+	RelayerWorkers    int
+	DefaultSlippage   float64
+	RelayerAttempts   int
+	RelayerLease      time.Duration
+	RelayerBatchSize  int
+	DCACheckEvery     time.Duration
+	DCAMaxFailures    int
 }
 
 // Load parses environment variables and produces a Config struct.
@@ -32,13 +49,36 @@ func Load() (Config, error) {
 		HTTPHost:          getEnv("HOST", "0.0.0.0"),
 		HTTPPort:          getEnvInt("PORT", 8090),
 		TonEndpoint:       getEnv("TON_RPC_ENDPOINT", "https://toncenter.com/api/v2/jsonRPC"),
+		TonEndpoints:      os.Getenv("TON_RPC_ENDPOINTS"),
 		TonAPIKey:         os.Getenv("TONCENTER_API_KEY"),
+		TonRateBurst:      getEnvInt("TON_RPC_RATE_BURST", 0),
+		TonCacheTTL:       getEnvDuration("TON_CACHE_TTL", 3*time.Second),
 		DedustAPIBase:     os.Getenv("DEDUST_API_BASE_URL"),
+		StonfiV1Router:    os.Getenv("STONFI_V1_ROUTER_ADDRESS"),
+		StonfiV1PTON:      os.Getenv("STONFI_V1_PTON_ADDRESS"),
+		StonfiV2Router:    os.Getenv("STONFI_V2_ROUTER_ADDRESS"),
+		StonfiV2PTON:      os.Getenv("STONFI_V2_PTON_ADDRESS"),
 		MaxWalletsPerUser: getEnvInt("WALLET_LIMIT_PER_USER", 3),
+		WalletVersion:     getEnv("WALLET_DEFAULT_VERSION", "v4r2"),
 		ShutdownTimeout:   getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
 		EnableGoRelayer:   getEnvBool("ENABLE_GO_RELAYER", false),
+		LimitCheckEvery:   getEnvDuration("LIMIT_CHECK_INTERVAL", 15*time.Second),
+		RelayerWorkers:    getEnvInt("RELAYER_WORKERS", 4),
+		DefaultSlippage:   getEnvFloat("SWAP_DEFAULT_SLIPPAGE", 5),
+		RelayerAttempts:   getEnvInt("RELAYER_MAX_ATTEMPTS", 5),
+		RelayerLease:      getEnvDuration("RELAYER_LEASE_DURATION", 60*time.Second),
+		RelayerBatchSize:  getEnvInt("RELAYER_BATCH_SIZE", 4),
+		DCACheckEvery:     getEnvDuration("DCA_CHECK_INTERVAL", 30*time.Second),
+		DCAMaxFailures:    getEnvInt("DCA_MAX_FAILURES", 3),
 	}
 
+	// Toncenter allows 1 rps without an API key and 10 rps on the free tier.
+	cfg.TonRateLimit = 1
+	if cfg.TonAPIKey != "" {
+		cfg.TonRateLimit = 10
+	}
+	cfg.TonRateLimit = getEnvFloat("TON_RPC_RATE_LIMIT", cfg.TonRateLimit)
+
 	if raw := strings.TrimSpace(os.Getenv("MASTER_KEY_DEV")); raw != "" {
 		key, err := decodeMasterKey(raw)
 		if err != nil {
@@ -92,6 +132,15 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvFloat(key string, fallback float64) float64 {
+	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
 		if parsed, err := time.ParseDuration(val); err == nil {
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DCA plan pause reasons.
+const (
+	DCAPauseFailures     = "repeated_failures"
+	DCAPauseInsufficient = "insufficient_balance"
+)
+
+const dcaPlanColumns = `id, user_id, wallet_id, token_address, platform, ton_amount::text, slippage_percent::text,
+		interval_seconds, total_runs, runs_done, status, pause_reason, consecutive_failures, next_run_at,
+		pending_order_id, last_order_id, created_at, updated_at`
+
+// InsertDCAPlanParams describes a new DCA plan.
+type InsertDCAPlanParams struct {
+	UserID          int64
+	WalletID        int64
+	TokenAddress    string
+	Platform        string
+	TonAmount       float64
+	SlippagePercent *float64
+	Interval        time.Duration
+	TotalRuns       int
+	StartAt         *time.Time
+}
+
+// InsertDCAPlan stores an active plan whose first run is at StartAt (now by default).
+func (s *Store) InsertDCAPlan(ctx context.Context, input InsertDCAPlanParams) (*DCAPlan, error) {
+	platform := input.Platform
+	if platform == "" {
+		platform = "dedust"
+	}
+	return scanDCAPlan(s.pool.QueryRow(ctx, `
+		INSERT INTO dca_plans (user_id, wallet_id, token_address, platform, ton_amount, slippage_percent,
+		                       interval_seconds, total_runs, next_run_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,COALESCE($9, NOW()))
+		RETURNING `+dcaPlanColumns,
+		input.UserID, input.WalletID, input.TokenAddress, platform, input.TonAmount, optionalFloat(input.SlippagePercent),
+		int64(input.Interval/time.Second), input.TotalRuns, optionalTime(input.StartAt)))
+}
+
+// ListDCAPlans returns the user's plans, newest first.
+func (s *Store) ListDCAPlans(ctx context.Context, userID int64) ([]DCAPlan, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+dcaPlanColumns+`
+		  FROM dca_plans
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DCAPlan
+	for rows.Next() {
+		plan, err := scanDCAPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *plan)
+	}
+	return items, rows.Err()
+}
+
+// GetDCAPlan loads a single plan by id.
+func (s *Store) GetDCAPlan(ctx context.Context, id int64) (*DCAPlan, error) {
+	plan, err := scanDCAPlan(s.pool.QueryRow(ctx, `SELECT `+dcaPlanColumns+` FROM dca_plans WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return plan, err
+}
+
+// SetDCAPlanStatus moves a user's plan between active, paused and canceled.
+// Resuming clears the failure streak and never schedules a run in the past.
+// It returns nil when the plan is missing, foreign, or already finished.
+func (s *Store) SetDCAPlanStatus(ctx context.Context, userID, id int64, status string) (*DCAPlan, error) {
+	plan, err := scanDCAPlan(s.pool.QueryRow(ctx, `
+		UPDATE dca_plans
+		   SET status = $3,
+		       pause_reason = CASE WHEN $3 = 'paused' THEN 'user' ELSE NULL END,
+		       consecutive_failures = CASE WHEN $3 = 'active' THEN 0 ELSE consecutive_failures END,
+		       next_run_at = CASE WHEN $3 = 'active' THEN GREATEST(next_run_at, NOW()) ELSE next_run_at END,
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status IN ('active', 'paused')
+		 RETURNING `+dcaPlanColumns, id, userID, status))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return plan, err
+}
+
+// PauseDCAPlan pauses an active plan with the given reason.
+func (s *Store) PauseDCAPlan(ctx context.Context, id int64, reason string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE dca_plans
+		   SET status = 'paused',
+		       pause_reason = $2,
+		       updated_at = NOW()
+		 WHERE id = $1 AND status = 'active'`, id, reason)
+	return err
+}
+
+// SettleDCAOrders folds the outcome of finished plan orders back into their
+// plans: a confirmed order counts as a run, resets the failure streak and
+// completes the plan on its last run; a failed one extends the streak and
+// leaves the run to be retried. Plans reaching maxFailures or running out of
+// balance are paused.
+func (s *Store) SettleDCAOrders(ctx context.Context, maxFailures int) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE dca_plans p
+		   SET pending_order_id = NULL,
+		       runs_done = CASE WHEN o.status = 'confirmed' THEN p.runs_done + 1 ELSE p.runs_done END,
+		       consecutive_failures = CASE WHEN o.status = 'confirmed' THEN 0 ELSE p.consecutive_failures + 1 END,
+		       status = CASE
+		                  WHEN o.status = 'confirmed' AND p.status IN ('active', 'paused')
+		                       AND p.runs_done + 1 >= p.total_runs THEN 'completed'
+		                  WHEN p.status <> 'active' OR o.status = 'confirmed' THEN p.status
+		                  WHEN o.error LIKE 'insufficient_ton%' OR p.consecutive_failures + 1 >= $1 THEN 'paused'
+		                  ELSE p.status END,
+		       pause_reason = CASE
+		                  WHEN o.status = 'confirmed' AND p.status IN ('active', 'paused')
+		                       AND p.runs_done + 1 >= p.total_runs THEN NULL
+		                  WHEN p.status <> 'active' OR o.status = 'confirmed' THEN p.pause_reason
+		                  WHEN o.error LIKE 'insufficient_ton%' THEN '`+DCAPauseInsufficient+`'
+		                  WHEN p.consecutive_failures + 1 >= $1 THEN '`+DCAPauseFailures+`'
+		                  ELSE p.pause_reason END,
+		       updated_at = NOW()
+		  FROM swap_orders o
+		 WHERE o.id = p.pending_order_id
+		   AND o.status IN ('confirmed', 'failed', 'dead', 'expired', 'canceled')`, maxFailures)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ListDueDCAPlans returns active plans whose next run is due and whose
+// previous order has finished.
+func (s *Store) ListDueDCAPlans(ctx context.Context, limit int) ([]DCAPlan, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+dcaPlanColumns+`
+		  FROM dca_plans
+		 WHERE status = 'active'
+		   AND next_run_at <= NOW()
+		   AND pending_order_id IS NULL
+		   AND runs_done < total_runs
+		 ORDER BY next_run_at ASC
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DCAPlan
+	for rows.Next() {
+		plan, err := scanDCAPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *plan)
+	}
+	return items, rows.Err()
+}
+
+// RunDCAPlan enqueues the next buy of a due plan and advances its schedule
+// in one transaction. The run is counted by SettleDCAOrders once the order
+// confirms. Missed runs are not replayed: the next run is one interval from
+// now if the schedule fell behind. It returns nil when the plan is no longer
+// due.
+func (s *Store) RunDCAPlan(ctx context.Context, id int64) (*SwapOrder, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	plan, err := scanDCAPlan(tx.QueryRow(ctx, `
+		SELECT `+dcaPlanColumns+`
+		  FROM dca_plans
+		 WHERE id = $1 AND status = 'active' AND next_run_at <= NOW()
+		   AND pending_order_id IS NULL AND runs_done < total_runs
+		 FOR UPDATE`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ord, err := scanSwapOrder(tx.QueryRow(ctx, `
+		INSERT INTO swap_orders (user_id, wallet_id, token_address, direction, platform, ton_amount, slippage_percent, status)
+		VALUES ($1,$2,$3,'buy',$4,$5::text::numeric,$6::text::numeric,'queued')
+		RETURNING `+swapOrderColumns,
+		plan.UserID, plan.WalletID, plan.TokenAddress, plan.Platform, plan.TonAmount, optionalString(plan.Slippage)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE dca_plans
+		   SET next_run_at = CASE
+		                       WHEN next_run_at + interval_seconds * INTERVAL '1 second' > NOW()
+		                       THEN next_run_at + interval_seconds * INTERVAL '1 second'
+		                       ELSE NOW() + interval_seconds * INTERVAL '1 second' END,
+		       pending_order_id = $2,
+		       last_order_id = $2,
+		       updated_at = NOW()
+		 WHERE id = $1`, id, ord.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	s.notifySwapOrder(ctx, ord.ID)
+	return ord, nil
+}
+
+func scanDCAPlan(row pgx.Row) (*DCAPlan, error) {
+	var plan DCAPlan
+	var slippage, pauseReason sql.NullString
+	var pendingOrder, lastOrder sql.NullInt64
+	if err := row.Scan(&plan.ID, &plan.UserID, &plan.WalletID, &plan.TokenAddress, &plan.Platform, &plan.TonAmount, &slippage,
+		&plan.IntervalSeconds, &plan.TotalRuns, &plan.RunsDone, &plan.Status, &pauseReason, &plan.ConsecutiveFailures,
+		&plan.NextRunAt, &pendingOrder, &lastOrder, &plan.CreatedAt, &plan.UpdatedAt); err != nil {
+		return nil, err
+	}
+	plan.Slippage = nullableString(slippage)
+	plan.PauseReason = nullableString(pauseReason)
+	plan.PendingOrderID = nullableInt(pendingOrder)
+	plan.LastOrderID = nullableInt(lastOrder)
+	return &plan, nil
+}
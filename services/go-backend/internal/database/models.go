@@ -3,9 +3,11 @@ package database
 import "time"
 
 type Wallet struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Address   string    `json:"address"`
+	ID      int64  `json:"id"`
+	UserID  int64  `json:"user_id"`
+	Address string `json:"address"`
+	// Version is the wallet contract: v4r2, v5r1 or highload_v3.
+	Version   string    `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -13,6 +15,7 @@ type WalletSecret struct {
 	ID                int64  `json:"id"`
 	UserID            int64  `json:"user_id"`
 	Address           string `json:"address"`
+	Version           string `json:"version"`
 	EncryptedMnemonic string `json:"encrypted_mnemonic"`
 }
 
@@ -27,25 +30,48 @@ type TradingProfile struct {
 	TonAmount      *string   `json:"ton_amount,omitempty"`
 	BuyLimitPrice  *string   `json:"buy_limit_price,omitempty"`
 	SellPercent    *string   `json:"sell_percent,omitempty"`
+	Slippage       *string   `json:"slippage_percent,omitempty"`
 	TradeMode      string    `json:"trade_mode"`
 	LastToken      *string   `json:"last_token,omitempty"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type SwapOrder struct {
-	ID           int64     `json:"id"`
-	UserID       int64     `json:"user_id"`
-	WalletID     int64     `json:"wallet_id"`
-	TokenAddress string    `json:"token_address"`
-	Direction    string    `json:"direction"`
-	TonAmount    string    `json:"ton_amount"`
-	LimitPrice   *string   `json:"limit_price,omitempty"`
-	SellPercent  *string   `json:"sell_percent,omitempty"`
-	Status       string    `json:"status"`
-	Error        *string   `json:"error,omitempty"`
-	TxHash       *string   `json:"tx_hash,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	WalletID     int64      `json:"wallet_id"`
+	TokenAddress string     `json:"token_address"`
+	Direction    string     `json:"direction"`
+	Platform     string     `json:"platform"`
+	TonAmount    *string    `json:"ton_amount,omitempty"`
+	LimitPrice   *string    `json:"limit_price,omitempty"`
+	SellPercent  *string    `json:"sell_percent,omitempty"`
+	Slippage     *string    `json:"slippage_percent,omitempty"`
+	Status       string     `json:"status"`
+	Error        *string    `json:"error,omitempty"`
+	TxHash       *string    `json:"tx_hash,omitempty"`
+	MsgHash      *string    `json:"msg_hash,omitempty"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty"`
+	ExitCode     *int64     `json:"exit_code,omitempty"`
+	ActionCode   *int64     `json:"action_result_code,omitempty"`
+	Attempts     int        `json:"attempts"`
+	NextAttempt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastError    *string    `json:"last_error,omitempty"`
+	LeaseUntil   *time.Time `json:"lease_until,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	// AmountIn is the raw amount offered: nanoTON for buys, jetton units for sells.
+	AmountIn     *string    `json:"amount_in,omitempty"`
+	TxLT         *int64     `json:"tx_lt,omitempty"`
+	FillStatus   *string    `json:"fill_status,omitempty"`
+	FilledTokens *string    `json:"filled_tokens,omitempty"`
+	FilledTon    *string    `json:"filled_ton,omitempty"`
+	FilledAt     *time.Time `json:"filled_at,omitempty"`
+	// OutMsgHash is the hash of the order's internal message body; orders
+	// batched into one external message share MsgHash but not this.
+	OutMsgHash *string   `json:"out_msg_hash,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type Position struct {
@@ -58,8 +84,83 @@ type Position struct {
 	TokenImage    *string   `json:"token_image,omitempty"`
 	Amount        string    `json:"amount"`
 	InvestedTon   string    `json:"invested_ton"`
+	AvgEntryPrice *string   `json:"avg_entry_price,omitempty"`
+	RealizedPnl   string    `json:"realized_pnl_ton"`
 	IsHidden      bool      `json:"is_hidden"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 	WalletAddress *string   `json:"wallet_address,omitempty"`
 }
+
+// PositionTrigger is a take-profit or stop-loss rule on a position. Percent
+// thresholds are relative to invested_ton; value thresholds compare the
+// position's quoted TON value directly.
+type PositionTrigger struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"user_id"`
+	PositionID    int64      `json:"position_id"`
+	Kind          string     `json:"kind"`
+	ThresholdType string     `json:"threshold_type"`
+	Threshold     string     `json:"threshold"`
+	SellPercent   string     `json:"sell_percent"`
+	Slippage      *string    `json:"slippage_percent,omitempty"`
+	Status        string     `json:"status"`
+	OrderID       *int64     `json:"order_id,omitempty"`
+	TriggeredAt   *time.Time `json:"triggered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ArmedTrigger is an active trigger joined with the position it watches.
+type ArmedTrigger struct {
+	PositionTrigger
+	WalletID     int64
+	TokenAddress string
+	Amount       string
+	InvestedTon  string
+	// Platform is the venue of the latest order for the position's token.
+	Platform string
+}
+
+// TrailingStop sells once the token price falls trail_percent below the
+// highest price seen since the stop was placed. Prices are TON per token.
+type TrailingStop struct {
+	ID             int64      `json:"id"`
+	UserID         int64      `json:"user_id"`
+	WalletID       int64      `json:"wallet_id"`
+	TokenAddress   string     `json:"token_address"`
+	Platform       string     `json:"platform"`
+	TrailPercent   string     `json:"trail_percent"`
+	SellPercent    string     `json:"sell_percent"`
+	Slippage       *string    `json:"slippage_percent,omitempty"`
+	HighWaterPrice *string    `json:"high_water_price,omitempty"`
+	LastPrice      *string    `json:"last_price,omitempty"`
+	Status         string     `json:"status"`
+	OrderID        *int64     `json:"order_id,omitempty"`
+	TriggeredAt    *time.Time `json:"triggered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// DCAPlan buys ton_amount of a token every interval_seconds until
+// total_runs orders have been placed.
+type DCAPlan struct {
+	ID                  int64     `json:"id"`
+	UserID              int64     `json:"user_id"`
+	WalletID            int64     `json:"wallet_id"`
+	TokenAddress        string    `json:"token_address"`
+	Platform            string    `json:"platform"`
+	TonAmount           string    `json:"ton_amount"`
+	Slippage            *string   `json:"slippage_percent,omitempty"`
+	IntervalSeconds     int64     `json:"interval_seconds"`
+	TotalRuns           int       `json:"total_runs"`
+	RunsDone            int       `json:"runs_done"`
+	Status              string    `json:"status"`
+	PauseReason         *string   `json:"pause_reason,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	NextRunAt           time.Time `json:"next_run_at"`
+	PendingOrderID      *int64    `json:"pending_order_id,omitempty"`
+	LastOrderID         *int64    `json:"last_order_id,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SwapOrderChannel is the NOTIFY channel signalled whenever a swap order
+// becomes ready for execution.
+const SwapOrderChannel = "swap_orders_ready"
+
+// notifySwapOrder wakes LISTENing relayers; payload is the order id. It is
+// best effort: relayers fall back to polling, so a lost wake-up only costs
+// latency and must not fail the write that preceded it.
+func (s *Store) notifySwapOrder(ctx context.Context, id int64) {
+	_, _ = s.pool.Exec(ctx, `SELECT pg_notify($1, $2::text)`, SwapOrderChannel, id)
+}
+
+// SwapOrderListener holds a dedicated connection LISTENing on SwapOrderChannel.
+type SwapOrderListener struct {
+	conn *pgxpool.Conn
+}
+
+// ListenSwapOrders acquires a pool connection and subscribes it to
+// SwapOrderChannel. Callers must Close the listener to return the connection.
+func (s *Store) ListenSwapOrders(ctx context.Context) (*SwapOrderListener, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+SwapOrderChannel); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("listen %s: %w", SwapOrderChannel, err)
+	}
+	return &SwapOrderListener{conn: conn}, nil
+}
+
+// Wait blocks until a notification arrives and returns its order id payload.
+func (l *SwapOrderListener) Wait(ctx context.Context) (string, error) {
+	n, err := l.conn.Conn().WaitForNotification(ctx)
+	if err != nil {
+		return "", err
+	}
+	return n.Payload, nil
+}
+
+// Close drops the connection: a LISTENing session must not go back to the
+// pool where other queries would share it.
+func (l *SwapOrderListener) Close() {
+	if l == nil || l.conn == nil {
+		return
+	}
+	_ = l.conn.Conn().Close(context.Background())
+	l.conn.Release()
+	l.conn = nil
+}
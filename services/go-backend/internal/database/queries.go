@@ -5,12 +5,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
 func (s *Store) ListWalletsByUser(ctx context.Context, userID int64) ([]Wallet, error) {
-	rows, err := s.pool.Query(ctx, `SELECT id, user_id, address, created_at FROM wallets WHERE user_id = $1 ORDER BY id ASC`, userID)
+	rows, err := s.pool.Query(ctx, `SELECT id, user_id, address, version, created_at FROM wallets WHERE user_id = $1 ORDER BY id ASC`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -19,7 +23,7 @@ func (s *Store) ListWalletsByUser(ctx context.Context, userID int64) ([]Wallet,
 	result := make([]Wallet, 0)
 	for rows.Next() {
 		var w Wallet
-		if err := rows.Scan(&w.ID, &w.UserID, &w.Address, &w.CreatedAt); err != nil {
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Address, &w.Version, &w.CreatedAt); err != nil {
 			return nil, err
 		}
 		result = append(result, w)
@@ -33,20 +37,20 @@ func (s *Store) CountWalletsByUser(ctx context.Context, userID int64) (int, erro
 	return count, err
 }
 
-func (s *Store) InsertWallet(ctx context.Context, userID int64, address, encryptedMnemonic string) (Wallet, error) {
+func (s *Store) InsertWallet(ctx context.Context, userID int64, address, encryptedMnemonic, version string) (Wallet, error) {
 	var w Wallet
-	err := s.pool.QueryRow(ctx, `INSERT INTO wallets (user_id, address, encrypted_mnemonic)
-		VALUES ($1,$2,$3)
-		RETURNING id, user_id, address, created_at`,
-		userID, address, encryptedMnemonic,
-	).Scan(&w.ID, &w.UserID, &w.Address, &w.CreatedAt)
+	err := s.pool.QueryRow(ctx, `INSERT INTO wallets (user_id, address, encrypted_mnemonic, version)
+		VALUES ($1,$2,$3,$4)
+		RETURNING id, user_id, address, version, created_at`,
+		userID, address, encryptedMnemonic, version,
+	).Scan(&w.ID, &w.UserID, &w.Address, &w.Version, &w.CreatedAt)
 	return w, err
 }
 
 func (s *Store) GetWalletByID(ctx context.Context, id int64) (*Wallet, error) {
 	var w Wallet
-	err := s.pool.QueryRow(ctx, `SELECT id, user_id, address, created_at FROM wallets WHERE id = $1`, id).
-		Scan(&w.ID, &w.UserID, &w.Address, &w.CreatedAt)
+	err := s.pool.QueryRow(ctx, `SELECT id, user_id, address, version, created_at FROM wallets WHERE id = $1`, id).
+		Scan(&w.ID, &w.UserID, &w.Address, &w.Version, &w.CreatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
@@ -55,14 +59,22 @@ func (s *Store) GetWalletByID(ctx context.Context, id int64) (*Wallet, error) {
 
 func (s *Store) GetWalletSecretByID(ctx context.Context, id int64) (*WalletSecret, error) {
 	var w WalletSecret
-	err := s.pool.QueryRow(ctx, `SELECT id, user_id, address, encrypted_mnemonic FROM wallets WHERE id = $1`, id).
-		Scan(&w.ID, &w.UserID, &w.Address, &w.EncryptedMnemonic)
+	err := s.pool.QueryRow(ctx, `SELECT id, user_id, address, version, encrypted_mnemonic FROM wallets WHERE id = $1`, id).
+		Scan(&w.ID, &w.UserID, &w.Address, &w.Version, &w.EncryptedMnemonic)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
 	return &w, err
 }
 
+// NextHighloadQueryID hands out highload wallet query ids from a shared
+// sequence, so several service instances never reuse one within its window.
+func (s *Store) NextHighloadQueryID(ctx context.Context) (uint32, error) {
+	var id int64
+	err := s.pool.QueryRow(ctx, `SELECT nextval('highload_query_ids') % 8388608`).Scan(&id)
+	return uint32(id), err
+}
+
 func (s *Store) DeleteWallet(ctx context.Context, id, userID int64) (bool, error) {
 	tag, err := s.pool.Exec(ctx, `DELETE FROM wallets WHERE id = $1 AND user_id = $2`, id, userID)
 	if err != nil {
@@ -91,12 +103,12 @@ func (s *Store) ListAllUserWallets(ctx context.Context) ([]UserWalletRef, error)
 
 func (s *Store) GetTradingProfile(ctx context.Context, userID int64) (*TradingProfile, error) {
 	var row TradingProfile
-	var tonAmount, buyLimit, sellPercent, lastToken sql.NullString
+	var tonAmount, buyLimit, sellPercent, slippage, lastToken sql.NullString
 	var activeWalletID sql.NullInt64
 	err := s.pool.QueryRow(ctx, `SELECT user_id, active_wallet_id, ton_amount::text, buy_limit_price::text,
-		sell_percent::text, trade_mode, last_token, updated_at
+		sell_percent::text, slippage_percent::text, trade_mode, last_token, updated_at
 		FROM user_trading_profiles WHERE user_id = $1`, userID).
-		Scan(&row.UserID, &activeWalletID, &tonAmount, &buyLimit, &sellPercent, &row.TradeMode, &lastToken, &row.UpdatedAt)
+		Scan(&row.UserID, &activeWalletID, &tonAmount, &buyLimit, &sellPercent, &slippage, &row.TradeMode, &lastToken, &row.UpdatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
@@ -104,6 +116,7 @@ func (s *Store) GetTradingProfile(ctx context.Context, userID int64) (*TradingPr
 	row.TonAmount = nullableString(tonAmount)
 	row.BuyLimitPrice = nullableString(buyLimit)
 	row.SellPercent = nullableString(sellPercent)
+	row.Slippage = nullableString(slippage)
 	row.LastToken = nullableString(lastToken)
 	return &row, err
 }
@@ -116,24 +129,25 @@ func (s *Store) UpsertTradingProfile(ctx context.Context, payload TradingProfile
 	}
 
 	var row TradingProfile
-	var tonAmount, buyLimit, sellPercent, lastToken sql.NullString
+	var tonAmount, buyLimit, sellPercent, slippage, lastToken sql.NullString
 	var activeWalletID sql.NullInt64
 	err := s.pool.QueryRow(ctx, `
-		INSERT INTO user_trading_profiles (user_id, active_wallet_id, ton_amount, buy_limit_price, sell_percent, trade_mode, last_token)
-		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		INSERT INTO user_trading_profiles (user_id, active_wallet_id, ton_amount, buy_limit_price, sell_percent, trade_mode, last_token, slippage_percent)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$9)
 		ON CONFLICT (user_id)
 		DO UPDATE SET
 			active_wallet_id = COALESCE(EXCLUDED.active_wallet_id, user_trading_profiles.active_wallet_id),
 			ton_amount = COALESCE(EXCLUDED.ton_amount, user_trading_profiles.ton_amount),
 			buy_limit_price = COALESCE(EXCLUDED.buy_limit_price, user_trading_profiles.buy_limit_price),
 			sell_percent = COALESCE(EXCLUDED.sell_percent, user_trading_profiles.sell_percent),
+			slippage_percent = COALESCE(EXCLUDED.slippage_percent, user_trading_profiles.slippage_percent),
 			trade_mode = CASE WHEN $8 THEN EXCLUDED.trade_mode ELSE user_trading_profiles.trade_mode END,
 			last_token = COALESCE(EXCLUDED.last_token, user_trading_profiles.last_token),
 			updated_at = NOW()
 		RETURNING user_id, active_wallet_id, ton_amount::text, buy_limit_price::text,
-		          sell_percent::text, trade_mode, last_token, updated_at
-	`, payload.UserID, optionalInt64(payload.ActiveWalletID), optionalFloat(payload.TonAmount), optionalFloat(payload.BuyLimitPrice), optionalFloat(payload.SellPercent), tradeMode, optionalString(payload.LastToken), hasTradeMode).
-		Scan(&row.UserID, &activeWalletID, &tonAmount, &buyLimit, &sellPercent, &row.TradeMode, &lastToken, &row.UpdatedAt)
+		          sell_percent::text, slippage_percent::text, trade_mode, last_token, updated_at
+	`, payload.UserID, optionalInt64(payload.ActiveWalletID), optionalFloat(payload.TonAmount), optionalFloat(payload.BuyLimitPrice), optionalFloat(payload.SellPercent), tradeMode, optionalString(payload.LastToken), hasTradeMode, optionalFloat(payload.SlippagePercent)).
+		Scan(&row.UserID, &activeWalletID, &tonAmount, &buyLimit, &sellPercent, &slippage, &row.TradeMode, &lastToken, &row.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -141,62 +155,439 @@ func (s *Store) UpsertTradingProfile(ctx context.Context, payload TradingProfile
 	row.TonAmount = nullableString(tonAmount)
 	row.BuyLimitPrice = nullableString(buyLimit)
 	row.SellPercent = nullableString(sellPercent)
+	row.Slippage = nullableString(slippage)
 	row.LastToken = nullableString(lastToken)
 	return &row, nil
 }
 
 func (s *Store) InsertSwapOrder(ctx context.Context, input InsertSwapOrderParams) (*SwapOrder, error) {
-	var ord SwapOrder
-	var limitPrice, sellPercent, errMsg, txHash sql.NullString
-	err := s.pool.QueryRow(ctx, `
-		INSERT INTO swap_orders (user_id, wallet_id, token_address, direction, ton_amount, limit_price, sell_percent)
-		VALUES ($1,$2,$3,$4,$5,$6,$7)
-		RETURNING id, user_id, wallet_id, token_address, direction,
-		          ton_amount::text, limit_price::text, sell_percent::text,
-		          status, error, tx_hash, created_at, updated_at
-	`, input.UserID, input.WalletID, input.TokenAddress, input.Direction, input.TonAmount, optionalFloat(input.LimitPrice), optionalFloat(input.SellPercent)).
-		Scan(&ord.ID, &ord.UserID, &ord.WalletID, &ord.TokenAddress, &ord.Direction,
-			&ord.TonAmount, &limitPrice, &sellPercent, &ord.Status, &errMsg, &txHash, &ord.CreatedAt, &ord.UpdatedAt)
+	platform := input.Platform
+	if platform == "" {
+		platform = "dedust"
+	}
+	// Limit orders wait for the price job before they enter the queue.
+	status := "queued"
+	if input.LimitPrice != nil {
+		status = "waiting_price"
+	}
+	row := s.pool.QueryRow(ctx, `
+		INSERT INTO swap_orders (user_id, wallet_id, token_address, direction, platform, ton_amount, limit_price, sell_percent, status, expires_at, slippage_percent,
+		                         token_symbol, token_name, token_image)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+		RETURNING `+swapOrderColumns,
+		input.UserID, input.WalletID, input.TokenAddress, input.Direction, platform, optionalFloat(input.TonAmount), optionalFloat(input.LimitPrice), optionalFloat(input.SellPercent), status, optionalTime(input.ExpiresAt), optionalFloat(input.SlippagePercent),
+		optionalString(input.TokenSymbol), optionalString(input.TokenName), optionalString(input.TokenImage))
+	ord, err := scanSwapOrder(row)
 	if err != nil {
 		return nil, err
 	}
-	ord.LimitPrice = nullableString(limitPrice)
-	ord.SellPercent = nullableString(sellPercent)
-	ord.Error = nullableString(errMsg)
-	ord.TxHash = nullableString(txHash)
-	return &ord, nil
+	if ord.Status == "queued" {
+		s.notifySwapOrder(ctx, ord.ID)
+	}
+	return ord, nil
+}
+
+// ListWaitingSwapOrders returns limit orders still waiting for their price.
+func (s *Store) ListWaitingSwapOrders(ctx context.Context, limit int) ([]SwapOrder, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+swapOrderColumns+`
+		  FROM swap_orders
+		 WHERE status = 'waiting_price'
+		   AND (expires_at IS NULL OR expires_at > NOW())
+		 ORDER BY created_at ASC
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []SwapOrder
+	for rows.Next() {
+		ord, err := scanSwapOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *ord)
+	}
+	return items, rows.Err()
+}
+
+// ExtendSwapOrderLease renews owner's lease on a processing order. It
+// reports false when the lease was lost.
+func (s *Store) ExtendSwapOrderLease(ctx context.Context, id int64, owner string, lease time.Duration) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE swap_orders
+		   SET lease_until = NOW() + $3 * INTERVAL '1 second'
+		 WHERE id = $1 AND lease_owner = $2 AND status = 'processing'`, id, owner, lease.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListExpiredLeases returns processing orders whose lease ran out.
+func (s *Store) ListExpiredLeases(ctx context.Context, limit int) ([]SwapOrder, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+swapOrderColumns+`
+		  FROM swap_orders
+		 WHERE status = 'processing'
+		   AND (lease_until IS NULL OR lease_until < NOW())
+		 ORDER BY updated_at ASC
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []SwapOrder
+	for rows.Next() {
+		ord, err := scanSwapOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *ord)
+	}
+	return items, rows.Err()
+}
+
+// ReapSwapOrder resolves an order with an expired lease: back to queued
+// (dropping any unsent message) or on to sent when its message was found
+// on-chain. It reports false if the lease was renewed in the meantime.
+func (s *Store) ReapSwapOrder(ctx context.Context, id int64, status string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE swap_orders
+		   SET status = $2,
+		       lease_owner = NULL,
+		       lease_until = NULL,
+		       last_error = CASE WHEN $2 = 'queued' THEN 'lease_expired' ELSE last_error END,
+		       msg_hash = CASE WHEN $2 = 'queued' THEN NULL ELSE msg_hash END,
+		       sent_at = CASE WHEN $2 = 'queued' THEN NULL ELSE sent_at END,
+		       valid_until = CASE WHEN $2 = 'queued' THEN NULL ELSE valid_until END,
+		       out_msg_hash = CASE WHEN $2 = 'queued' THEN NULL ELSE out_msg_hash END,
+		       updated_at = NOW()
+		 WHERE id = $1
+		   AND status = 'processing'
+		   AND (lease_until IS NULL OR lease_until < NOW())`, id, status)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if status == "queued" {
+		s.notifySwapOrder(ctx, id)
+	}
+	return true, nil
+}
+
+// ErrSwapOrderReleased is returned by SignSwapOrders when none of the orders
+// is still processing under the caller's lease, e.g. because all were canceled.
+var ErrSwapOrderReleased = errors.New("swap order released")
+
+// SignedSwapOrder is the per-order part of a message recorded by
+// SignSwapOrders.
+type SignedSwapOrder struct {
+	ID         int64
+	AmountIn   string
+	OutMsgHash string
+}
+
+// GetSwapOrder loads a single order by id.
+func (s *Store) GetSwapOrder(ctx context.Context, id int64) (*SwapOrder, error) {
+	ord, err := scanSwapOrder(s.pool.QueryRow(ctx, `
+		SELECT `+swapOrderColumns+`
+		  FROM swap_orders
+		 WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return ord, err
+}
+
+// CancelSwapOrder moves a user's order to canceled if nothing has been signed
+// for it yet: waiting and queued orders, and processing ones whose message
+// hash is not recorded. It returns nil when the order cannot be canceled.
+func (s *Store) CancelSwapOrder(ctx context.Context, userID, id int64) (*SwapOrder, error) {
+	ord, err := scanSwapOrder(s.pool.QueryRow(ctx, `
+		UPDATE swap_orders
+		   SET status = 'canceled',
+		       lease_owner = NULL,
+		       lease_until = NULL,
+		       next_attempt_at = NULL,
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2
+		   AND (status IN ('waiting_price', 'queued')
+		        OR (status = 'processing' AND msg_hash IS NULL))
+		 RETURNING `+swapOrderColumns, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return ord, err
+}
+
+// SignSwapOrders locks a batch of processing orders of one wallet, keeps
+// those owner still holds, and records the external message produced by sign
+// for them before the locks are released. sign receives the ids still held,
+// in input order; it runs under the locks, so it must not wait on the
+// network. A cancel either commits first, in which case the order is
+// left out of the message, or waits and then finds the message hash.
+// ErrSwapOrderReleased is returned when no order is held any more.
+func (s *Store) SignSwapOrders(ctx context.Context, owner string, orders []SignedSwapOrder, sign func(held []int64) (UpdateSwapOrderOptions, error)) ([]int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ids := make([]int64, len(orders))
+	for i, ord := range orders {
+		ids[i] = ord.ID
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT id
+		  FROM swap_orders
+		 WHERE id = ANY($1) AND status = 'processing' AND lease_owner = $2
+		 ORDER BY id
+		 FOR UPDATE`, ids, owner)
+	if err != nil {
+		return nil, err
+	}
+	lockedIDs := make(map[int64]bool, len(orders))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lockedIDs[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var held []int64
+	for _, ord := range orders {
+		if lockedIDs[ord.ID] {
+			held = append(held, ord.ID)
+		}
+	}
+	if len(held) == 0 {
+		return nil, ErrSwapOrderReleased
+	}
+	opts, err := sign(held)
+	if err != nil {
+		return nil, err
+	}
+	for _, ord := range orders {
+		if !lockedIDs[ord.ID] {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE swap_orders SET
+				amount_in = COALESCE($2::text::numeric, amount_in),
+				out_msg_hash = $3,
+				msg_hash = $4,
+				sent_at = $5,
+				valid_until = $6,
+				updated_at = NOW()
+			WHERE id = $1`,
+			ord.ID, ord.AmountIn, ord.OutMsgHash, opts.MsgHash, optionalTime(opts.SentAt), optionalTime(opts.ValidUntil)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return held, nil
+}
+
+// RetrySwapOrder puts a failed attempt back into status (queued, or
+// waiting_price for limit orders) to be picked up again at next.
+func (s *Store) RetrySwapOrder(ctx context.Context, id int64, status string, next time.Time, lastError string) (*SwapOrder, error) {
+	row := s.pool.QueryRow(ctx, `
+		UPDATE swap_orders
+		   SET status = $2,
+		       next_attempt_at = $3,
+		       last_error = $4,
+		       msg_hash = NULL,
+		       sent_at = NULL,
+		       valid_until = NULL,
+		       out_msg_hash = NULL,
+		       updated_at = NOW()
+		 WHERE id = $1 AND status <> 'canceled'
+		 RETURNING `+swapOrderColumns, id, status, next, lastError)
+	ord, err := scanSwapOrder(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return ord, err
+}
+
+// RequeueSwapOrder returns a user's dead or failed order to the queue with a
+// fresh attempt budget. Everything recorded about the previous attempts is
+// cleared so the reaper and the confirm loop cannot match the old message.
+func (s *Store) RequeueSwapOrder(ctx context.Context, userID, id int64) (*SwapOrder, error) {
+	row := s.pool.QueryRow(ctx, `
+		UPDATE swap_orders
+		   SET status = 'queued',
+		       attempts = 0,
+		       next_attempt_at = NULL,
+		       error = NULL,
+		       last_error = NULL,
+		       msg_hash = NULL,
+		       sent_at = NULL,
+		       valid_until = NULL,
+		       out_msg_hash = NULL,
+		       tx_hash = NULL,
+		       tx_lt = NULL,
+		       exit_code = NULL,
+		       action_result_code = NULL,
+		       lease_owner = NULL,
+		       lease_until = NULL,
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status IN ('dead', 'failed')
+		 RETURNING `+swapOrderColumns, id, userID)
+	ord, err := scanSwapOrder(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.notifySwapOrder(ctx, ord.ID)
+	return ord, nil
+}
+
+// CountActiveSwapOrders returns the number of unfinished orders per status.
+func (s *Store) CountActiveSwapOrders(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT status, COUNT(*)
+		  FROM swap_orders
+		 WHERE status IN ('queued', 'waiting_price', 'processing', 'sent', 'dead')
+		 GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{"queued": 0, "waiting_price": 0, "processing": 0, "sent": 0, "dead": 0}
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// ListSentSwapOrders returns broadcast orders awaiting on-chain confirmation.
+func (s *Store) ListSentSwapOrders(ctx context.Context, limit int) ([]SwapOrder, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+swapOrderColumns+`
+		  FROM swap_orders
+		 WHERE status = 'sent'
+		 ORDER BY sent_at ASC NULLS FIRST
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []SwapOrder
+	for rows.Next() {
+		ord, err := scanSwapOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *ord)
+	}
+	return items, rows.Err()
+}
+
+// SetSwapOrderPlatform reroutes an order to another venue, e.g. when a
+// launchpad token has migrated to a DEX pool.
+func (s *Store) SetSwapOrderPlatform(ctx context.Context, id int64, platform string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE swap_orders
+		   SET platform = $2,
+		       updated_at = NOW()
+		 WHERE id = $1`, id, platform)
+	return err
+}
+
+// ActivateSwapOrder moves a waiting limit order into the execution queue.
+func (s *Store) ActivateSwapOrder(ctx context.Context, id int64) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE swap_orders
+		   SET status = 'queued',
+		       updated_at = NOW()
+		 WHERE id = $1 AND status = 'waiting_price'`, id)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	s.notifySwapOrder(ctx, id)
+	return true, nil
+}
+
+// ExpireSwapOrders marks waiting limit orders past expires_at as expired.
+func (s *Store) ExpireSwapOrders(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE swap_orders
+		   SET status = 'expired',
+		       updated_at = NOW()
+		 WHERE status = 'waiting_price'
+		   AND expires_at IS NOT NULL
+		   AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
 
 func (s *Store) UpdateSwapOrderStatus(ctx context.Context, id int64, status string, opts UpdateSwapOrderOptions) (*SwapOrder, error) {
-	var ord SwapOrder
-	var limitPrice, sellPercent, errMsg, txHash sql.NullString
-	err := s.pool.QueryRow(ctx, `
+	row := s.pool.QueryRow(ctx, `
 		UPDATE swap_orders SET
 			status = $2,
 			error = COALESCE($3, error),
 			tx_hash = COALESCE($4, tx_hash),
+			msg_hash = COALESCE($5, msg_hash),
+			sent_at = COALESCE($6, sent_at),
+			valid_until = COALESCE($7, valid_until),
+			exit_code = COALESCE($8, exit_code),
+			action_result_code = COALESCE($9, action_result_code),
+			tx_lt = COALESCE($10, tx_lt),
+			amount_in = COALESCE($11::text::numeric, amount_in),
+			last_error = COALESCE($3, last_error),
 			updated_at = NOW()
-		WHERE id = $1
-		RETURNING id, user_id, wallet_id, token_address, direction,
-		          ton_amount::text, limit_price::text, sell_percent::text,
-		          status, error, tx_hash, created_at, updated_at
-	`, id, status, opts.Error, opts.TxHash).
-		Scan(&ord.ID, &ord.UserID, &ord.WalletID, &ord.TokenAddress, &ord.Direction,
-			&ord.TonAmount, &limitPrice, &sellPercent, &ord.Status, &errMsg, &txHash, &ord.CreatedAt, &ord.UpdatedAt)
+		WHERE id = $1 AND status <> 'canceled'
+		RETURNING `+swapOrderColumns,
+		id, status, opts.Error, opts.TxHash, opts.MsgHash, optionalTime(opts.SentAt), optionalTime(opts.ValidUntil),
+		optionalInt64(opts.ExitCode), optionalInt64(opts.ActionCode), optionalInt64(opts.TxLT), optionalString(opts.AmountIn))
+	ord, err := scanSwapOrder(row)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
-	if err != nil {
-		return nil, err
-	}
-	ord.LimitPrice = nullableString(limitPrice)
-	ord.SellPercent = nullableString(sellPercent)
-	ord.Error = nullableString(errMsg)
-	ord.TxHash = nullableString(txHash)
-	return &ord, nil
+	return ord, err
 }
 
-func (s *Store) ClaimNextSwapOrder(ctx context.Context) (*SwapOrder, error) {
+// ClaimSwapOrderBatch moves the oldest due queued order, plus up to max-1
+// more due orders of the same wallet, to processing under a lease held by
+// owner for the given duration. Orders are serialized per wallet: a wallet
+// with an order in processing or sent is skipped, since a second message
+// signed with the same seqno would be dropped. A batch never holds two
+// orders for the same token nor more than one sell, so each payout can
+// still be attributed to its order. Highload wallets have no seqno: their
+// orders are claimed one at a time but run in parallel, as long as no busy
+// order of the wallet trades the same token or is a sell next to a sell.
+func (s *Store) ClaimSwapOrderBatch(ctx context.Context, owner string, lease time.Duration, max int) ([]SwapOrder, error) {
+	if max < 1 {
+		max = 1
+	}
 	conn, err := s.pool.Acquire(ctx)
 	if err != nil {
 		return nil, err
@@ -209,80 +600,366 @@ func (s *Store) ClaimNextSwapOrder(ctx context.Context) (*SwapOrder, error) {
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	var ord SwapOrder
-	var limitPrice, sellPercent, errMsg, txHash sql.NullString
-	row := tx.QueryRow(ctx, `
-		SELECT id, user_id, wallet_id, token_address, direction,
-		       ton_amount::text, limit_price::text, sell_percent::text,
-		       status, error, tx_hash, created_at, updated_at
-		  FROM swap_orders
-		 WHERE status = 'queued'
-		 ORDER BY created_at ASC
-		 FOR UPDATE SKIP LOCKED
-		 LIMIT 1`)
-	if err := row.Scan(&ord.ID, &ord.UserID, &ord.WalletID, &ord.TokenAddress, &ord.Direction,
-		&ord.TonAmount, &limitPrice, &sellPercent, &ord.Status, &errMsg, &txHash, &ord.CreatedAt, &ord.UpdatedAt); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			if err := tx.Rollback(ctx); err != nil {
-				return nil, err
-			}
-			return nil, nil
+	var id, walletID int64
+	var token, direction string
+	var highload bool
+	err = tx.QueryRow(ctx, `
+		SELECT q.id, q.wallet_id, q.token_address, q.direction, w.version = 'highload_v3'
+		  FROM swap_orders q
+		  JOIN wallets w ON w.id = q.wallet_id
+		 WHERE q.status = 'queued'
+		   AND (q.next_attempt_at IS NULL OR q.next_attempt_at <= NOW())
+		   AND NOT EXISTS (
+		         SELECT 1 FROM swap_orders busy
+		          WHERE busy.wallet_id = q.wallet_id
+		            AND busy.status IN ('processing', 'sent')
+		            AND (w.version <> 'highload_v3'
+		                 OR busy.token_address = q.token_address
+		                 OR (busy.direction = 'sell' AND q.direction = 'sell')))
+		 ORDER BY q.created_at ASC
+		 FOR UPDATE OF q SKIP LOCKED
+		 LIMIT 1`).Scan(&id, &walletID, &token, &direction, &highload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := tx.Rollback(ctx); err != nil {
+			return nil, err
 		}
+		return nil, nil
+	}
+	if err != nil {
 		return nil, err
 	}
 
+	// Two workers may pick different orders of the same wallet concurrently.
+	// The wallet lock is held until commit, so re-checking under it sees any
+	// claim committed by the other worker.
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, walletID); err != nil {
+		return nil, err
+	}
+	var busy bool
 	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (
+		  SELECT 1 FROM swap_orders
+		   WHERE wallet_id = $1 AND status IN ('processing', 'sent')
+		     AND (NOT $2 OR token_address = $3 OR (direction = 'sell' AND $4 = 'sell')))`,
+		walletID, highload, token, direction).Scan(&busy); err != nil {
+		return nil, err
+	}
+	if busy {
+		return nil, nil
+	}
+
+	ids := []int64{id}
+	if max > 1 && !highload {
+		if ids, err = batchCandidates(ctx, tx, id, walletID, max); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := tx.Query(ctx, `
 		UPDATE swap_orders
 		   SET status = 'processing',
 		       error = NULL,
+		       attempts = attempts + 1,
+		       next_attempt_at = NULL,
+		       lease_owner = $2,
+		       lease_until = NOW() + $3 * INTERVAL '1 second',
 		       updated_at = NOW()
-		 WHERE id = $1
-		 RETURNING id, user_id, wallet_id, token_address, direction,
-		       ton_amount::text, limit_price::text, sell_percent::text,
-		       status, error, tx_hash, created_at, updated_at`,
-		ord.ID).
-		Scan(&ord.ID, &ord.UserID, &ord.WalletID, &ord.TokenAddress, &ord.Direction,
-			&ord.TonAmount, &limitPrice, &sellPercent, &ord.Status, &errMsg, &txHash, &ord.CreatedAt, &ord.UpdatedAt); err != nil {
+		 WHERE id = ANY($1)
+		 RETURNING `+swapOrderColumns, ids, owner, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	var items []SwapOrder
+	for rows.Next() {
+		ord, err := scanSwapOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, *ord)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
 
 	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
-	ord.LimitPrice = nullableString(limitPrice)
-	ord.SellPercent = nullableString(sellPercent)
-	ord.Error = nullableString(errMsg)
-	ord.TxHash = nullableString(txHash)
-	return &ord, nil
+	return items, nil
 }
 
-func (s *Store) UpsertUserPosition(ctx context.Context, input UpsertUserPositionParams) (*Position, error) {
-	var pos Position
+// batchCandidates picks the due orders of walletID that may join first in
+// one batch, oldest first.
+func batchCandidates(ctx context.Context, tx pgx.Tx, first, walletID int64, max int) ([]int64, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, token_address, direction
+		  FROM swap_orders
+		 WHERE wallet_id = $1
+		   AND status = 'queued'
+		   AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+		 ORDER BY id = $2 DESC, created_at ASC
+		 FOR UPDATE SKIP LOCKED
+		 LIMIT $3`, walletID, first, max*4)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, max)
+	tokens := make(map[string]bool)
+	sells := 0
+	for rows.Next() {
+		var id int64
+		var token, direction string
+		if err := rows.Scan(&id, &token, &direction); err != nil {
+			return nil, err
+		}
+		if id != first && (tokens[token] || (direction == "sell" && sells > 0)) {
+			continue
+		}
+		tokens[token] = true
+		if direction == "sell" {
+			sells++
+		}
+		ids = append(ids, id)
+		if len(ids) == max {
+			break
+		}
+	}
+	return ids, rows.Err()
+}
+
+// UnclaimSwapOrder returns an order claimed by owner to the queue without
+// counting the attempt, e.g. when it did not fit into its wallet's batch.
+func (s *Store) UnclaimSwapOrder(ctx context.Context, id int64, owner string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE swap_orders
+		   SET status = 'queued',
+		       attempts = GREATEST(attempts - 1, 0),
+		       lease_owner = NULL,
+		       lease_until = NULL,
+		       updated_at = NOW()
+		 WHERE id = $1 AND status = 'processing' AND lease_owner = $2 AND msg_hash IS NULL`, id, owner)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	s.notifySwapOrder(ctx, id)
+	return true, nil
+}
+
+// ApplySwapFill records the settled fill of a confirmed order and folds it
+// into the user's position in one transaction. Buys add to the amount and
+// cost basis; sells release cost basis pro rata and book the difference to
+// realized PnL. It reports false when the order was already settled.
+func (s *Store) ApplySwapFill(ctx context.Context, fill SwapFill) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var userID, walletID int64
+	var tokenAddress, direction string
 	var tokenSymbol, tokenName, tokenImage sql.NullString
-	err := s.pool.QueryRow(ctx, `
-		INSERT INTO user_positions (user_id, wallet_id, token_address, token_symbol, token_name, token_image, amount, invested_ton)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-		ON CONFLICT (user_id, wallet_id, token_address)
-		DO UPDATE SET
-			amount = user_positions.amount + EXCLUDED.amount,
-			invested_ton = user_positions.invested_ton + EXCLUDED.invested_ton,
-			token_symbol = COALESCE(EXCLUDED.token_symbol, user_positions.token_symbol),
-			token_name = COALESCE(EXCLUDED.token_name, user_positions.token_name),
-			token_image = COALESCE(EXCLUDED.token_image, user_positions.token_image),
-			is_hidden = FALSE,
+	err = tx.QueryRow(ctx, `
+		UPDATE swap_orders SET
+			fill_status = $2,
+			filled_tokens = $3::text::numeric,
+			filled_ton = $4::text::numeric,
+			filled_at = NOW(),
 			updated_at = NOW()
-		RETURNING id, user_id, wallet_id, token_address, token_symbol, token_name, token_image,
-		          amount::text, invested_ton::text, is_hidden, created_at, updated_at
-	`, input.UserID, input.WalletID, input.TokenAddress, optionalString(input.TokenSymbol), optionalString(input.TokenName), optionalString(input.TokenImage), input.Amount, input.InvestedTon).
-		Scan(&pos.ID, &pos.UserID, &pos.WalletID, &pos.TokenAddress, &tokenSymbol, &tokenName, &tokenImage,
-			&pos.Amount, &pos.InvestedTon, &pos.IsHidden, &pos.CreatedAt, &pos.UpdatedAt)
+		WHERE id = $1 AND fill_status IS NULL
+		RETURNING user_id, wallet_id, token_address, direction, token_symbol, token_name, token_image`,
+		fill.OrderID, fill.Status, optionalString(fill.Tokens), optionalString(fill.Ton)).
+		Scan(&userID, &walletID, &tokenAddress, &direction, &tokenSymbol, &tokenName, &tokenImage)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if fill.Status == FillStatusFilled && fill.Tokens != nil && fill.Ton != nil {
+		if direction == "sell" {
+			err = sellFromPosition(ctx, tx, userID, walletID, tokenAddress, *fill.Tokens, *fill.Ton)
+		} else {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO user_positions (user_id, wallet_id, token_address, token_symbol, token_name, token_image, amount, invested_ton, avg_entry_price)
+				VALUES ($1,$2,$3,$4,$5,$6,$7::text::numeric,$8::text::numeric,$8::text::numeric / NULLIF($7::text::numeric, 0))
+				ON CONFLICT (user_id, wallet_id, token_address)
+				DO UPDATE SET
+					amount = user_positions.amount + EXCLUDED.amount,
+					invested_ton = user_positions.invested_ton + EXCLUDED.invested_ton,
+					avg_entry_price = (user_positions.invested_ton + EXCLUDED.invested_ton) / NULLIF(user_positions.amount + EXCLUDED.amount, 0),
+					token_symbol = COALESCE(EXCLUDED.token_symbol, user_positions.token_symbol),
+					token_name = COALESCE(EXCLUDED.token_name, user_positions.token_name),
+					token_image = COALESCE(EXCLUDED.token_image, user_positions.token_image),
+					is_hidden = FALSE,
+					updated_at = NOW()`,
+				userID, walletID, tokenAddress, tokenSymbol, tokenName, tokenImage, *fill.Tokens, *fill.Ton)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+// sellFromPosition applies a filled sell to the locked position row. A
+// position the bot never saw bought (amount 0 or no row) is left alone.
+func sellFromPosition(ctx context.Context, tx pgx.Tx, userID, walletID int64, token, tokens, ton string) error {
+	var amountText, investedText string
+	err := tx.QueryRow(ctx, `
+		SELECT amount::text, invested_ton::text
+		  FROM user_positions
+		 WHERE user_id = $1 AND wallet_id = $2 AND token_address = $3 AND amount > 0
+		   FOR UPDATE`, userID, walletID, token).Scan(&amountText, &investedText)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var values [4]*big.Rat
+	for i, text := range []string{amountText, investedText, tokens, ton} {
+		if values[i], err = parseDecimal(text); err != nil {
+			return err
+		}
+	}
+	settled := settleSell(values[0], values[1], values[2], values[3])
+	_, err = tx.Exec(ctx, `
+		UPDATE user_positions SET
+			amount = $4::text::numeric,
+			invested_ton = $5::text::numeric,
+			realized_pnl_ton = realized_pnl_ton + $6::text::numeric,
+			updated_at = NOW()
+		WHERE user_id = $1 AND wallet_id = $2 AND token_address = $3`,
+		userID, walletID, token, formatDecimal(settled.Amount), formatDecimal(settled.Invested), formatDecimal(settled.RealizedPnl))
+	return err
+}
+
+// sellSettlement is a position after a sell.
+type sellSettlement struct {
+	Amount   *big.Rat
+	Invested *big.Rat
+	// RealizedPnl is the PnL booked by this sell alone.
+	RealizedPnl *big.Rat
+}
+
+// settleSell releases the cost basis of the sold share of a position pro
+// rata and books proceeds minus that basis as realized PnL. Selling more
+// than the position (tokens bought outside the bot) closes it and releases
+// its whole basis.
+func settleSell(amount, invested, sold, proceeds *big.Rat) sellSettlement {
+	share := new(big.Rat).Set(sold)
+	if share.Cmp(amount) > 0 {
+		share.Set(amount)
+	}
+	released := new(big.Rat).Mul(invested, share)
+	released.Quo(released, amount)
+	return sellSettlement{
+		Amount:      new(big.Rat).Sub(amount, share),
+		Invested:    new(big.Rat).Sub(invested, released),
+		RealizedPnl: new(big.Rat).Sub(proceeds, released),
+	}
+}
+
+// decimalScale is the number of fractional digits stored for computed
+// position values; it exceeds any jetton's decimals.
+const decimalScale = 18
+
+func parseDecimal(text string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(text))
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", text)
+	}
+	return r, nil
+}
+
+// formatDecimal renders r with decimalScale digits, trimming trailing zeros.
+func formatDecimal(r *big.Rat) string {
+	s := r.FloatString(decimalScale)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || s == "-" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// ListUnsettledSwapOrders returns confirmed orders whose fill has not been
+// read back from the chain yet.
+func (s *Store) ListUnsettledSwapOrders(ctx context.Context, limit int) ([]SwapOrder, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+swapOrderColumns+`
+		  FROM swap_orders
+		 WHERE status = 'confirmed' AND fill_status IS NULL AND tx_lt IS NOT NULL
+		 ORDER BY updated_at ASC
+		 LIMIT $1`, limit)
 	if err != nil {
 		return nil, err
 	}
-	pos.TokenSymbol = nullableString(tokenSymbol)
-	pos.TokenName = nullableString(tokenName)
-	pos.TokenImage = nullableString(tokenImage)
-	return &pos, nil
+	defer rows.Close()
+
+	var items []SwapOrder
+	for rows.Next() {
+		ord, err := scanSwapOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *ord)
+	}
+	return items, rows.Err()
+}
+
+// ListWalletTokens returns the jetton masters a wallet has held or traded
+// through the bot, most recently active first.
+func (s *Store) ListWalletTokens(ctx context.Context, walletID int64, limit int) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT token_address
+		  FROM (
+		        SELECT token_address, updated_at FROM user_positions WHERE wallet_id = $1
+		        UNION ALL
+		        SELECT token_address, updated_at FROM swap_orders
+		         WHERE wallet_id = $1 AND status IN ('sent', 'confirmed')
+		       ) t
+		 GROUP BY token_address
+		 ORDER BY MAX(updated_at) DESC
+		 LIMIT $2`, walletID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetPositionAmount returns the token amount of the user's position in token
+// on wallet, or nil when there is no such position.
+func (s *Store) GetPositionAmount(ctx context.Context, userID, walletID int64, token string) (*string, error) {
+	var amount string
+	err := s.pool.QueryRow(ctx, `
+		SELECT amount::text
+		  FROM user_positions
+		 WHERE user_id = $1 AND wallet_id = $2 AND token_address = $3`, userID, walletID, token).Scan(&amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &amount, nil
 }
 
 func (s *Store) ListUserPositions(ctx context.Context, userID int64, includeHidden bool) ([]Position, error) {
@@ -292,7 +969,7 @@ func (s *Store) ListUserPositions(ctx context.Context, userID int64, includeHidd
 	}
 	query := fmt.Sprintf(`
 		SELECT p.id, p.user_id, p.wallet_id, p.token_address, p.token_symbol, p.token_name, p.token_image,
-		       p.amount::text, p.invested_ton::text, p.is_hidden, p.created_at, p.updated_at,
+		       p.amount::text, p.invested_ton::text, p.avg_entry_price::text, p.realized_pnl_ton::text, p.is_hidden, p.created_at, p.updated_at,
 		       w.address AS wallet_address
 		  FROM user_positions p
 		  JOIN wallets w ON w.id = p.wallet_id
@@ -310,14 +987,15 @@ func (s *Store) ListUserPositions(ctx context.Context, userID int64, includeHidd
 	var positions []Position
 	for rows.Next() {
 		var pos Position
-		var tokenSymbol, tokenName, tokenImage, walletAddr sql.NullString
+		var tokenSymbol, tokenName, tokenImage, avgEntry, walletAddr sql.NullString
 		if err := rows.Scan(&pos.ID, &pos.UserID, &pos.WalletID, &pos.TokenAddress, &tokenSymbol, &tokenName, &tokenImage,
-			&pos.Amount, &pos.InvestedTon, &pos.IsHidden, &pos.CreatedAt, &pos.UpdatedAt, &walletAddr); err != nil {
+			&pos.Amount, &pos.InvestedTon, &avgEntry, &pos.RealizedPnl, &pos.IsHidden, &pos.CreatedAt, &pos.UpdatedAt, &walletAddr); err != nil {
 			return nil, err
 		}
 		pos.TokenSymbol = nullableString(tokenSymbol)
 		pos.TokenName = nullableString(tokenName)
 		pos.TokenImage = nullableString(tokenImage)
+		pos.AvgEntryPrice = nullableString(avgEntry)
 		pos.WalletAddress = nullableString(walletAddr)
 		positions = append(positions, pos)
 	}
@@ -326,17 +1004,17 @@ func (s *Store) ListUserPositions(ctx context.Context, userID int64, includeHidd
 
 func (s *Store) SetUserPositionHidden(ctx context.Context, userID, positionID int64, hidden bool) (*Position, error) {
 	var pos Position
-	var tokenSymbol, tokenName, tokenImage sql.NullString
+	var tokenSymbol, tokenName, tokenImage, avgEntry sql.NullString
 	err := s.pool.QueryRow(ctx, `
 		UPDATE user_positions
 		   SET is_hidden = $3,
 		       updated_at = NOW()
 		 WHERE id = $1 AND user_id = $2
 		 RETURNING id, user_id, wallet_id, token_address, token_symbol, token_name, token_image,
-		       amount::text, invested_ton::text, is_hidden, created_at, updated_at
+		       amount::text, invested_ton::text, avg_entry_price::text, realized_pnl_ton::text, is_hidden, created_at, updated_at
 	`, positionID, userID, hidden).
 		Scan(&pos.ID, &pos.UserID, &pos.WalletID, &pos.TokenAddress, &tokenSymbol, &tokenName, &tokenImage,
-			&pos.Amount, &pos.InvestedTon, &pos.IsHidden, &pos.CreatedAt, &pos.UpdatedAt)
+			&pos.Amount, &pos.InvestedTon, &avgEntry, &pos.RealizedPnl, &pos.IsHidden, &pos.CreatedAt, &pos.UpdatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
@@ -346,14 +1024,13 @@ func (s *Store) SetUserPositionHidden(ctx context.Context, userID, positionID in
 	pos.TokenSymbol = nullableString(tokenSymbol)
 	pos.TokenName = nullableString(tokenName)
 	pos.TokenImage = nullableString(tokenImage)
+	pos.AvgEntryPrice = nullableString(avgEntry)
 	return &pos, nil
 }
 
 func (s *Store) ListSwapOrders(ctx context.Context, userID int64) ([]SwapOrder, error) {
 	rows, err := s.pool.Query(ctx, `
-		SELECT id, user_id, wallet_id, token_address, direction,
-		       ton_amount::text, limit_price::text, sell_percent::text,
-		       status, error, tx_hash, created_at, updated_at
+		SELECT `+swapOrderColumns+`
 		  FROM swap_orders
 		 WHERE user_id = $1
 		 ORDER BY created_at DESC`, userID)
@@ -364,30 +1041,70 @@ func (s *Store) ListSwapOrders(ctx context.Context, userID int64) ([]SwapOrder,
 
 	var items []SwapOrder
 	for rows.Next() {
-		var ord SwapOrder
-		var limitPrice, sellPercent, errMsg, txHash sql.NullString
-		if err := rows.Scan(&ord.ID, &ord.UserID, &ord.WalletID, &ord.TokenAddress, &ord.Direction,
-			&ord.TonAmount, &limitPrice, &sellPercent, &ord.Status, &errMsg, &txHash, &ord.CreatedAt, &ord.UpdatedAt); err != nil {
+		ord, err := scanSwapOrder(rows)
+		if err != nil {
 			return nil, err
 		}
-		ord.LimitPrice = nullableString(limitPrice)
-		ord.SellPercent = nullableString(sellPercent)
-		ord.Error = nullableString(errMsg)
-		ord.TxHash = nullableString(txHash)
-		items = append(items, ord)
+		items = append(items, *ord)
 	}
 	return items, rows.Err()
 }
 
+// swapOrderColumns is the column list shared by every swap_orders read.
+const swapOrderColumns = `id, user_id, wallet_id, token_address, direction, platform,
+		ton_amount::text, limit_price::text, sell_percent::text, slippage_percent::text,
+		status, error, tx_hash, msg_hash, sent_at, valid_until, exit_code, action_result_code,
+		attempts, next_attempt_at, last_error, lease_until, expires_at,
+		amount_in::text, tx_lt, fill_status, filled_tokens::text, filled_ton::text, filled_at,
+		out_msg_hash, created_at, updated_at`
+
+func scanSwapOrder(row pgx.Row) (*SwapOrder, error) {
+	var ord SwapOrder
+	var tonAmount, limitPrice, sellPercent, slippage, errMsg, txHash, msgHash, lastError sql.NullString
+	var amountIn, fillStatus, filledTokens, filledTon, outMsgHash sql.NullString
+	var sentAt, validUntil, nextAttempt, leaseUntil, expiresAt, filledAt sql.NullTime
+	var exitCode, actionCode, txLT sql.NullInt64
+	if err := row.Scan(&ord.ID, &ord.UserID, &ord.WalletID, &ord.TokenAddress, &ord.Direction, &ord.Platform,
+		&tonAmount, &limitPrice, &sellPercent, &slippage, &ord.Status, &errMsg, &txHash, &msgHash, &sentAt, &validUntil,
+		&exitCode, &actionCode, &ord.Attempts, &nextAttempt, &lastError, &leaseUntil, &expiresAt,
+		&amountIn, &txLT, &fillStatus, &filledTokens, &filledTon, &filledAt, &outMsgHash, &ord.CreatedAt, &ord.UpdatedAt); err != nil {
+		return nil, err
+	}
+	ord.NextAttempt = nullableTime(nextAttempt)
+	ord.LeaseUntil = nullableTime(leaseUntil)
+	ord.LastError = nullableString(lastError)
+	ord.MsgHash = nullableString(msgHash)
+	ord.SentAt = nullableTime(sentAt)
+	ord.ValidUntil = nullableTime(validUntil)
+	ord.ExitCode = nullableInt(exitCode)
+	ord.ActionCode = nullableInt(actionCode)
+	ord.ExpiresAt = nullableTime(expiresAt)
+	ord.TonAmount = nullableString(tonAmount)
+	ord.LimitPrice = nullableString(limitPrice)
+	ord.SellPercent = nullableString(sellPercent)
+	ord.Slippage = nullableString(slippage)
+	ord.Error = nullableString(errMsg)
+	ord.TxHash = nullableString(txHash)
+	ord.AmountIn = nullableString(amountIn)
+	ord.TxLT = nullableInt(txLT)
+	ord.FillStatus = nullableString(fillStatus)
+	ord.FilledTokens = nullableString(filledTokens)
+	ord.FilledTon = nullableString(filledTon)
+	ord.FilledAt = nullableTime(filledAt)
+	ord.OutMsgHash = nullableString(outMsgHash)
+	return &ord, nil
+}
+
 // TradingProfileUpdate describes the upsert payload.
 type TradingProfileUpdate struct {
-	UserID         int64
-	ActiveWalletID *int64
-	TonAmount      *float64
-	BuyLimitPrice  *float64
-	SellPercent    *float64
-	TradeMode      *string
-	LastToken      *string
+	UserID          int64
+	ActiveWalletID  *int64
+	TonAmount       *float64
+	BuyLimitPrice   *float64
+	SellPercent     *float64
+	SlippagePercent *float64
+	TradeMode       *string
+	LastToken       *string
 }
 
 // InsertSwapOrderParams stores swap order input data.
@@ -396,27 +1113,49 @@ type InsertSwapOrderParams struct {
 	WalletID     int64
 	TokenAddress string
 	Direction    string
-	TonAmount    float64
+	Platform     string
+	TonAmount    *float64 // nil for sells, which use SellPercent
 	LimitPrice   *float64
 	SellPercent  *float64
+	// SlippagePercent bounds how far below the quote the fill may land.
+	SlippagePercent *float64
+	ExpiresAt       *time.Time
+	// Token metadata copied onto the position once the order fills.
+	TokenSymbol *string
+	TokenName   *string
+	TokenImage  *string
 }
 
 // UpdateSwapOrderOptions allows optional error / tx overrides.
 type UpdateSwapOrderOptions struct {
 	Error  *string
 	TxHash *string
+	// Broadcast details recorded when the order moves to sent.
+	MsgHash    *string
+	SentAt     *time.Time
+	ValidUntil *time.Time
+	// Wallet transaction outcome recorded on confirmation.
+	ExitCode   *int64
+	ActionCode *int64
+	TxLT       *int64
+	// AmountIn is the raw offered amount resolved at execution.
+	AmountIn *string
 }
 
-// UpsertUserPositionParams holds position metrics.
-type UpsertUserPositionParams struct {
-	UserID       int64
-	WalletID     int64
-	TokenAddress string
-	TokenSymbol  *string
-	TokenName    *string
-	TokenImage   *string
-	Amount       float64
-	InvestedTon  float64
+// Fill outcomes recorded on confirmed swap orders.
+const (
+	FillStatusFilled   = "filled"
+	FillStatusRefunded = "refunded"
+	FillStatusUnknown  = "unknown"
+)
+
+// SwapFill is the on-chain result of a confirmed order. Tokens is in whole
+// jetton units and Ton in TON; both are nil unless Status is filled.
+type SwapFill struct {
+	OrderID int64
+	Status  string
+	Tokens  *string
+	Ton     *string
 }
 
 func nullableString(ns sql.NullString) *string {
@@ -435,6 +1174,21 @@ func nullableInt(n sql.NullInt64) *int64 {
 	return &val
 }
 
+func nullableTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	val := t.Time
+	return &val
+}
+
+func optionalTime(v *time.Time) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
 func optionalString(v *string) any {
 	if v == nil {
 		return nil
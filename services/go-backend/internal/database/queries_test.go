@@ -0,0 +1,96 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+)
+
+func rat(t *testing.T, s string) *big.Rat {
+	t.Helper()
+	r, err := parseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSettleSell(t *testing.T) {
+	tests := []struct {
+		name                 string
+		amount, invested     string
+		sold, proceeds       string
+		wantAmount, wantCost string
+		wantPnl              string
+	}{
+		{
+			name:   "half at a profit",
+			amount: "1000", invested: "10",
+			sold: "500", proceeds: "7.5",
+			wantAmount: "500", wantCost: "5", wantPnl: "2.5",
+		},
+		{
+			name:   "quarter at a loss",
+			amount: "1000", invested: "10",
+			sold: "250", proceeds: "1",
+			wantAmount: "750", wantCost: "7.5", wantPnl: "-1.5",
+		},
+		{
+			name:   "whole position",
+			amount: "1234.5678", invested: "3.21",
+			sold: "1234.5678", proceeds: "3.21",
+			wantAmount: "0", wantCost: "0", wantPnl: "0",
+		},
+		{
+			// Tokens received outside the bot: only the tracked position's
+			// basis is released.
+			name:   "more than the position",
+			amount: "100", invested: "2",
+			sold: "150", proceeds: "4.5",
+			wantAmount: "0", wantCost: "0", wantPnl: "2.5",
+		},
+		{
+			name:   "repeating basis",
+			amount: "3", invested: "1",
+			sold: "1", proceeds: "0.5",
+			wantAmount: "2", wantCost: "0.666666666666666667", wantPnl: "0.166666666666666667",
+		},
+	}
+	for _, tt := range tests {
+		got := settleSell(rat(t, tt.amount), rat(t, tt.invested), rat(t, tt.sold), rat(t, tt.proceeds))
+		if s := formatDecimal(got.Amount); s != tt.wantAmount {
+			t.Errorf("%s: amount = %s, want %s", tt.name, s, tt.wantAmount)
+		}
+		if s := formatDecimal(got.Invested); s != tt.wantCost {
+			t.Errorf("%s: invested = %s, want %s", tt.name, s, tt.wantCost)
+		}
+		if s := formatDecimal(got.RealizedPnl); s != tt.wantPnl {
+			t.Errorf("%s: realized pnl = %s, want %s", tt.name, s, tt.wantPnl)
+		}
+	}
+}
+
+func TestSettleSellKeepsAverageEntry(t *testing.T) {
+	amount, invested := rat(t, "800"), rat(t, "12")
+	got := settleSell(amount, invested, rat(t, "300"), rat(t, "1"))
+	before := new(big.Rat).Quo(invested, amount)
+	after := new(big.Rat).Quo(got.Invested, got.Amount)
+	if before.Cmp(after) != 0 {
+		t.Errorf("avg entry changed from %s to %s", before.FloatString(9), after.FloatString(9))
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := map[string]string{
+		"0":                        "0",
+		"1.5000":                   "1.5",
+		"-2":                       "-2",
+		"1/3":                      "0.333333333333333333",
+		"-1/100000000000000000000": "0",
+		"123456789012345678901234": "123456789012345678901234",
+	}
+	for in, want := range tests {
+		if got := formatDecimal(rat(t, in)); got != want {
+			t.Errorf("formatDecimal(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -59,9 +59,12 @@ CREATE TABLE IF NOT EXISTS wallets (
   user_id BIGINT NOT NULL,
   address TEXT NOT NULL,
   encrypted_mnemonic TEXT NOT NULL,
+  version TEXT NOT NULL DEFAULT 'v4r2',
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 CREATE INDEX IF NOT EXISTS idx_wallets_user_id ON wallets(user_id);
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS version TEXT NOT NULL DEFAULT 'v4r2';
+CREATE SEQUENCE IF NOT EXISTS highload_query_ids;
 
 CREATE TABLE IF NOT EXISTS user_trading_profiles (
   user_id BIGINT PRIMARY KEY,
@@ -69,6 +72,7 @@ CREATE TABLE IF NOT EXISTS user_trading_profiles (
   ton_amount NUMERIC,
   buy_limit_price NUMERIC,
   sell_percent NUMERIC,
+  slippage_percent NUMERIC,
   trade_mode TEXT NOT NULL DEFAULT 'buy',
   last_token TEXT,
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
@@ -78,6 +82,7 @@ ALTER TABLE user_trading_profiles
   ADD COLUMN IF NOT EXISTS ton_amount NUMERIC,
   ADD COLUMN IF NOT EXISTS buy_limit_price NUMERIC,
   ADD COLUMN IF NOT EXISTS sell_percent NUMERIC,
+  ADD COLUMN IF NOT EXISTS slippage_percent NUMERIC,
   ADD COLUMN IF NOT EXISTS trade_mode TEXT NOT NULL DEFAULT 'buy',
   ADD COLUMN IF NOT EXISTS last_token TEXT,
   ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
@@ -91,12 +96,35 @@ CREATE TABLE IF NOT EXISTS swap_orders (
   wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
   token_address TEXT NOT NULL,
   direction TEXT NOT NULL CHECK (direction IN ('buy','sell')),
-  ton_amount NUMERIC NOT NULL,
+  platform TEXT NOT NULL DEFAULT 'dedust',
+  ton_amount NUMERIC,
   limit_price NUMERIC,
   sell_percent NUMERIC,
+  slippage_percent NUMERIC,
   status TEXT NOT NULL DEFAULT 'queued',
   error TEXT,
   tx_hash TEXT,
+  msg_hash TEXT,
+  sent_at TIMESTAMPTZ,
+  valid_until TIMESTAMPTZ,
+  exit_code INTEGER,
+  action_result_code INTEGER,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ,
+  last_error TEXT,
+  lease_owner TEXT,
+  lease_until TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ,
+  token_symbol TEXT,
+  token_name TEXT,
+  token_image TEXT,
+  amount_in NUMERIC,
+  tx_lt BIGINT,
+  fill_status TEXT,
+  filled_tokens NUMERIC,
+  filled_ton NUMERIC,
+  filled_at TIMESTAMPTZ,
+  out_msg_hash TEXT,
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -106,9 +134,35 @@ CREATE INDEX IF NOT EXISTS idx_swap_orders_wallet ON swap_orders(wallet_id);
 ALTER TABLE swap_orders
   ADD COLUMN IF NOT EXISTS limit_price NUMERIC,
   ADD COLUMN IF NOT EXISTS sell_percent NUMERIC,
+  ADD COLUMN IF NOT EXISTS slippage_percent NUMERIC,
   ADD COLUMN IF NOT EXISTS error TEXT,
   ADD COLUMN IF NOT EXISTS tx_hash TEXT,
-  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+  ADD COLUMN IF NOT EXISTS platform TEXT NOT NULL DEFAULT 'dedust',
+  ADD COLUMN IF NOT EXISTS msg_hash TEXT,
+  ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS valid_until TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS exit_code INTEGER,
+  ADD COLUMN IF NOT EXISTS action_result_code INTEGER,
+  ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS last_error TEXT,
+  ADD COLUMN IF NOT EXISTS lease_owner TEXT,
+  ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS token_symbol TEXT,
+  ADD COLUMN IF NOT EXISTS token_name TEXT,
+  ADD COLUMN IF NOT EXISTS token_image TEXT,
+  ADD COLUMN IF NOT EXISTS amount_in NUMERIC,
+  ADD COLUMN IF NOT EXISTS tx_lt BIGINT,
+  ADD COLUMN IF NOT EXISTS fill_status TEXT,
+  ADD COLUMN IF NOT EXISTS filled_tokens NUMERIC,
+  ADD COLUMN IF NOT EXISTS filled_ton NUMERIC,
+  ADD COLUMN IF NOT EXISTS filled_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS out_msg_hash TEXT,
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ALTER COLUMN ton_amount DROP NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_swap_orders_status ON swap_orders(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_swap_orders_wallet_status ON swap_orders(wallet_id, status);
 
 CREATE TABLE IF NOT EXISTS user_positions (
   id BIGSERIAL PRIMARY KEY,
@@ -120,10 +174,83 @@ CREATE TABLE IF NOT EXISTS user_positions (
   token_image TEXT,
   amount NUMERIC NOT NULL DEFAULT 0,
   invested_ton NUMERIC NOT NULL DEFAULT 0,
+  avg_entry_price NUMERIC,
+  realized_pnl_ton NUMERIC NOT NULL DEFAULT 0,
   is_hidden BOOLEAN NOT NULL DEFAULT FALSE,
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   UNIQUE(user_id, wallet_id, token_address)
 );
 CREATE INDEX IF NOT EXISTS idx_positions_user ON user_positions(user_id);
+
+ALTER TABLE user_positions
+  ADD COLUMN IF NOT EXISTS avg_entry_price NUMERIC,
+  ADD COLUMN IF NOT EXISTS realized_pnl_ton NUMERIC NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS position_triggers (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  position_id BIGINT NOT NULL REFERENCES user_positions(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('take_profit','stop_loss')),
+  threshold_type TEXT NOT NULL CHECK (threshold_type IN ('percent','value')),
+  threshold NUMERIC NOT NULL,
+  sell_percent NUMERIC NOT NULL DEFAULT 100,
+  slippage_percent NUMERIC,
+  status TEXT NOT NULL DEFAULT 'active',
+  order_id BIGINT REFERENCES swap_orders(id) ON DELETE SET NULL,
+  triggered_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_position_triggers_user ON position_triggers(user_id);
+CREATE INDEX IF NOT EXISTS idx_position_triggers_active ON position_triggers(status, position_id);
+
+ALTER TABLE position_triggers
+  ADD COLUMN IF NOT EXISTS slippage_percent NUMERIC;
+
+CREATE TABLE IF NOT EXISTS trailing_stops (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+  token_address TEXT NOT NULL,
+  platform TEXT NOT NULL DEFAULT 'dedust',
+  trail_percent NUMERIC NOT NULL,
+  sell_percent NUMERIC NOT NULL DEFAULT 100,
+  slippage_percent NUMERIC,
+  high_water_price NUMERIC,
+  last_price NUMERIC,
+  status TEXT NOT NULL DEFAULT 'active',
+  order_id BIGINT REFERENCES swap_orders(id) ON DELETE SET NULL,
+  triggered_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_trailing_stops_user ON trailing_stops(user_id);
+CREATE INDEX IF NOT EXISTS idx_trailing_stops_status ON trailing_stops(status, updated_at);
+
+ALTER TABLE trailing_stops
+  ADD COLUMN IF NOT EXISTS slippage_percent NUMERIC;
+
+CREATE TABLE IF NOT EXISTS dca_plans (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+  token_address TEXT NOT NULL,
+  platform TEXT NOT NULL DEFAULT 'dedust',
+  ton_amount NUMERIC NOT NULL,
+  slippage_percent NUMERIC,
+  interval_seconds INTEGER NOT NULL,
+  total_runs INTEGER NOT NULL,
+  runs_done INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL DEFAULT 'active',
+  pause_reason TEXT,
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  next_run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  pending_order_id BIGINT REFERENCES swap_orders(id) ON DELETE SET NULL,
+  last_order_id BIGINT REFERENCES swap_orders(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_dca_plans_user ON dca_plans(user_id);
+CREATE INDEX IF NOT EXISTS idx_dca_plans_due ON dca_plans(status, next_run_at);
 `
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const trailingStopColumns = `id, user_id, wallet_id, token_address, platform, trail_percent::text, sell_percent::text,
+		slippage_percent::text, high_water_price::text, last_price::text, status, order_id, triggered_at, created_at, updated_at`
+
+// InsertTrailingStopParams describes a new trailing stop.
+type InsertTrailingStopParams struct {
+	UserID          int64
+	WalletID        int64
+	TokenAddress    string
+	Platform        string
+	TrailPercent    float64
+	SellPercent     float64
+	SlippagePercent *float64
+}
+
+// InsertTrailingStop stores a new active trailing stop. The high-water mark
+// is filled in by the first price check.
+func (s *Store) InsertTrailingStop(ctx context.Context, input InsertTrailingStopParams) (*TrailingStop, error) {
+	platform := input.Platform
+	if platform == "" {
+		platform = "dedust"
+	}
+	return scanTrailingStop(s.pool.QueryRow(ctx, `
+		INSERT INTO trailing_stops (user_id, wallet_id, token_address, platform, trail_percent, sell_percent, slippage_percent)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		RETURNING `+trailingStopColumns,
+		input.UserID, input.WalletID, input.TokenAddress, platform, input.TrailPercent, input.SellPercent,
+		optionalFloat(input.SlippagePercent)))
+}
+
+// ListTrailingStops returns the user's trailing stops, newest first.
+func (s *Store) ListTrailingStops(ctx context.Context, userID int64) ([]TrailingStop, error) {
+	return s.queryTrailingStops(ctx, `
+		SELECT `+trailingStopColumns+`
+		  FROM trailing_stops
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC`, userID)
+}
+
+// ListActiveTrailingStops returns stops the price job should evaluate,
+// least recently checked first.
+func (s *Store) ListActiveTrailingStops(ctx context.Context, limit int) ([]TrailingStop, error) {
+	return s.queryTrailingStops(ctx, `
+		SELECT `+trailingStopColumns+`
+		  FROM trailing_stops
+		 WHERE status = 'active'
+		 ORDER BY updated_at ASC
+		 LIMIT $1`, limit)
+}
+
+// CancelTrailingStop deactivates an active stop of the user. It returns nil
+// when there is no such active stop.
+func (s *Store) CancelTrailingStop(ctx context.Context, userID, id int64) (*TrailingStop, error) {
+	stop, err := scanTrailingStop(s.pool.QueryRow(ctx, `
+		UPDATE trailing_stops
+		   SET status = 'canceled',
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status = 'active'
+		 RETURNING `+trailingStopColumns, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return stop, err
+}
+
+// RecordTrailingPrice stores the latest price and raises the high-water mark
+// when price exceeds it. The mark never moves down.
+func (s *Store) RecordTrailingPrice(ctx context.Context, id int64, price string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE trailing_stops
+		   SET last_price = $2::text::numeric,
+		       high_water_price = GREATEST(COALESCE(high_water_price, 0), $2::text::numeric),
+		       updated_at = NOW()
+		 WHERE id = $1 AND status = 'active'`, id, price)
+	return err
+}
+
+// FireTrailingStop marks an active stop as triggered at price and queues its
+// sell order in the same transaction. Stops created before slippage was
+// stored on them take the owner's profile slippage. It returns nil if the
+// stop was no longer active.
+func (s *Store) FireTrailingStop(ctx context.Context, id int64, price string) (*SwapOrder, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	stop, err := scanTrailingStop(tx.QueryRow(ctx, `
+		UPDATE trailing_stops
+		   SET status = 'triggered',
+		       last_price = $2::text::numeric,
+		       triggered_at = NOW(),
+		       updated_at = NOW()
+		 WHERE id = $1 AND status = 'active'
+		 RETURNING `+trailingStopColumns, id, price))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ord, err := scanSwapOrder(tx.QueryRow(ctx, `
+		INSERT INTO swap_orders (user_id, wallet_id, token_address, direction, platform, sell_percent, slippage_percent, status)
+		VALUES ($1,$2,$3,'sell',$4,$5::text::numeric,
+		        COALESCE($6::text::numeric, (SELECT slippage_percent FROM user_trading_profiles WHERE user_id = $1)),
+		        'queued')
+		RETURNING `+swapOrderColumns,
+		stop.UserID, stop.WalletID, stop.TokenAddress, stop.Platform, stop.SellPercent, optionalString(stop.Slippage)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE trailing_stops SET order_id = $2 WHERE id = $1`, id, ord.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	s.notifySwapOrder(ctx, ord.ID)
+	return ord, nil
+}
+
+func (s *Store) queryTrailingStops(ctx context.Context, query string, args ...any) ([]TrailingStop, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TrailingStop
+	for rows.Next() {
+		stop, err := scanTrailingStop(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *stop)
+	}
+	return items, rows.Err()
+}
+
+func scanTrailingStop(row pgx.Row) (*TrailingStop, error) {
+	var stop TrailingStop
+	var slippage, highWater, lastPrice sql.NullString
+	var orderID sql.NullInt64
+	var triggeredAt sql.NullTime
+	if err := row.Scan(&stop.ID, &stop.UserID, &stop.WalletID, &stop.TokenAddress, &stop.Platform, &stop.TrailPercent,
+		&stop.SellPercent, &slippage, &highWater, &lastPrice, &stop.Status, &orderID, &triggeredAt, &stop.CreatedAt, &stop.UpdatedAt); err != nil {
+		return nil, err
+	}
+	stop.Slippage = nullableString(slippage)
+	stop.HighWaterPrice = nullableString(highWater)
+	stop.LastPrice = nullableString(lastPrice)
+	stop.OrderID = nullableInt(orderID)
+	stop.TriggeredAt = nullableTime(triggeredAt)
+	return &stop, nil
+}
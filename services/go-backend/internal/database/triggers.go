@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const positionTriggerColumns = `id, user_id, position_id, kind, threshold_type, threshold::text, sell_percent::text,
+		slippage_percent::text, status, order_id, triggered_at, created_at, updated_at`
+
+// InsertPositionTriggerParams describes a new TP/SL rule.
+type InsertPositionTriggerParams struct {
+	UserID          int64
+	PositionID      int64
+	Kind            string
+	ThresholdType   string
+	Threshold       float64
+	SellPercent     float64
+	SlippagePercent *float64
+}
+
+// InsertPositionTrigger adds a rule to one of the user's positions. It
+// returns nil when the position does not belong to the user.
+func (s *Store) InsertPositionTrigger(ctx context.Context, input InsertPositionTriggerParams) (*PositionTrigger, error) {
+	trig, err := scanPositionTrigger(s.pool.QueryRow(ctx, `
+		INSERT INTO position_triggers (user_id, position_id, kind, threshold_type, threshold, sell_percent, slippage_percent)
+		SELECT p.user_id, p.id, $3, $4, $5, $6, $7
+		  FROM user_positions p
+		 WHERE p.id = $2 AND p.user_id = $1
+		RETURNING `+positionTriggerColumns,
+		input.UserID, input.PositionID, input.Kind, input.ThresholdType, input.Threshold, input.SellPercent,
+		optionalFloat(input.SlippagePercent)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return trig, err
+}
+
+// ListPositionTriggers returns the user's triggers, newest first.
+func (s *Store) ListPositionTriggers(ctx context.Context, userID int64) ([]PositionTrigger, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+positionTriggerColumns+`
+		  FROM position_triggers
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []PositionTrigger
+	for rows.Next() {
+		trig, err := scanPositionTrigger(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *trig)
+	}
+	return items, rows.Err()
+}
+
+// CancelPositionTrigger deactivates an active trigger of the user. It
+// returns nil when there is no such active trigger.
+func (s *Store) CancelPositionTrigger(ctx context.Context, userID, id int64) (*PositionTrigger, error) {
+	trig, err := scanPositionTrigger(s.pool.QueryRow(ctx, `
+		UPDATE position_triggers
+		   SET status = 'canceled',
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status = 'active'
+		 RETURNING `+positionTriggerColumns, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return trig, err
+}
+
+// ListArmedTriggers returns active triggers on non-empty positions.
+func (s *Store) ListArmedTriggers(ctx context.Context, limit int) ([]ArmedTrigger, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT t.id, t.user_id, t.position_id, t.kind, t.threshold_type, t.threshold::text, t.sell_percent::text,
+		       t.slippage_percent::text, t.status, t.order_id, t.triggered_at, t.created_at, t.updated_at,
+		       p.wallet_id, p.token_address, p.amount::text, p.invested_ton::text,
+		       COALESCE((SELECT o.platform FROM swap_orders o
+		                  WHERE o.wallet_id = p.wallet_id AND o.token_address = p.token_address
+		                  ORDER BY o.created_at DESC LIMIT 1), 'dedust')
+		  FROM position_triggers t
+		  JOIN user_positions p ON p.id = t.position_id
+		 WHERE t.status = 'active' AND p.amount > 0
+		 ORDER BY t.updated_at ASC
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ArmedTrigger
+	for rows.Next() {
+		var item ArmedTrigger
+		var slippage sql.NullString
+		var orderID sql.NullInt64
+		var triggeredAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.UserID, &item.PositionID, &item.Kind, &item.ThresholdType, &item.Threshold,
+			&item.SellPercent, &slippage, &item.Status, &orderID, &triggeredAt, &item.CreatedAt, &item.UpdatedAt,
+			&item.WalletID, &item.TokenAddress, &item.Amount, &item.InvestedTon, &item.Platform); err != nil {
+			return nil, err
+		}
+		item.Slippage = nullableString(slippage)
+		item.OrderID = nullableInt(orderID)
+		item.TriggeredAt = nullableTime(triggeredAt)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// FirePositionTrigger marks an active trigger as triggered and queues the
+// sell order it describes in the same transaction. Triggers created before
+// slippage was stored on them take the owner's profile slippage. A full exit
+// cancels the
+// position's other active triggers. It returns nil if the trigger was no
+// longer active.
+func (s *Store) FirePositionTrigger(ctx context.Context, id int64) (*SwapOrder, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var positionID int64
+	var sellPercent float64
+	var slippage sql.NullString
+	err = tx.QueryRow(ctx, `
+		UPDATE position_triggers
+		   SET status = 'triggered',
+		       triggered_at = NOW(),
+		       updated_at = NOW()
+		 WHERE id = $1 AND status = 'active'
+		 RETURNING position_id, sell_percent::float8, slippage_percent::text`, id).Scan(&positionID, &sellPercent, &slippage)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ord, err := scanSwapOrder(tx.QueryRow(ctx, `
+		INSERT INTO swap_orders (user_id, wallet_id, token_address, direction, platform, sell_percent, slippage_percent, status)
+		SELECT p.user_id, p.wallet_id, p.token_address, 'sell',
+		       COALESCE((SELECT o.platform FROM swap_orders o
+		                  WHERE o.wallet_id = p.wallet_id AND o.token_address = p.token_address
+		                  ORDER BY o.created_at DESC LIMIT 1), 'dedust'),
+		       $2, COALESCE($3::text::numeric,
+		                    (SELECT tp.slippage_percent FROM user_trading_profiles tp WHERE tp.user_id = p.user_id)),
+		       'queued'
+		  FROM user_positions p
+		 WHERE p.id = $1
+		RETURNING `+swapOrderColumns, positionID, sellPercent, slippage))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE position_triggers SET order_id = $2 WHERE id = $1`, id, ord.ID); err != nil {
+		return nil, err
+	}
+	if sellPercent >= 100 {
+		if _, err := tx.Exec(ctx, `
+			UPDATE position_triggers
+			   SET status = 'canceled',
+			       updated_at = NOW()
+			 WHERE position_id = $1 AND status = 'active'`, positionID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	s.notifySwapOrder(ctx, ord.ID)
+	return ord, nil
+}
+
+func scanPositionTrigger(row pgx.Row) (*PositionTrigger, error) {
+	var trig PositionTrigger
+	var slippage sql.NullString
+	var orderID sql.NullInt64
+	var triggeredAt sql.NullTime
+	if err := row.Scan(&trig.ID, &trig.UserID, &trig.PositionID, &trig.Kind, &trig.ThresholdType, &trig.Threshold,
+		&trig.SellPercent, &slippage, &trig.Status, &orderID, &triggeredAt, &trig.CreatedAt, &trig.UpdatedAt); err != nil {
+		return nil, err
+	}
+	trig.Slippage = nullableString(slippage)
+	trig.OrderID = nullableInt(orderID)
+	trig.TriggeredAt = nullableTime(triggeredAt)
+	return &trig, nil
+}
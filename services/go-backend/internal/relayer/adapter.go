@@ -0,0 +1,162 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+// ChainReader is the read-only chain access DEX adapters rely on. Keeping it
+// this small lets adapters run against recorded get-method responses.
+type ChainReader interface {
+	RunGetMethod(ctx context.Context, addr, method string, args ...ton.StackEntry) (*ton.GetMethodResult, error)
+	GetJettonWalletAddress(ctx context.Context, master, owner *address.Address) (*address.Address, error)
+}
+
+// SwapRequest describes a single swap handed to an adapter.
+type SwapRequest struct {
+	QueryID   uint64
+	Direction string // "buy" (TON→jetton) or "sell" (jetton→TON)
+	Owner     *address.Address
+	Token     *address.Address
+	// JettonWallet is the owner's jetton wallet; required for sells.
+	JettonWallet *address.Address
+	AmountIn     *big.Int
+	MinOut       *big.Int
+}
+
+// Quote is an adapter's view of a swap before it is signed.
+type Quote struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// TonRequired is the total TON the wallet attaches to the swap messages.
+	TonRequired *big.Int
+	// Pool is the venue contract the swap will route through.
+	Pool *address.Address
+}
+
+// SwapResult is a payout or refund delivered to the owner. Venues echo the
+// query id of the swap request, which ties the message to its order.
+type SwapResult struct {
+	QueryID uint64
+	// AmountOut is nanoTON for ParseResult, jetton units for jetton credits.
+	AmountOut *big.Int
+}
+
+// DEXAdapter executes swaps on one trading venue.
+type DEXAdapter interface {
+	// Name is the platform key stored in swap_orders.platform.
+	Name() string
+	// Quote estimates the swap output and the TON the wallet must attach.
+	Quote(ctx context.Context, req SwapRequest) (*Quote, error)
+	// BuildSwap returns the wallet messages that perform the swap.
+	BuildSwap(ctx context.Context, req SwapRequest, quote *Quote) ([]*wallet.Message, error)
+	// ParseResult decodes a TON payout or refund the venue sent to the owner
+	// for a swap. It returns ErrUnrecognizedResult for any other message.
+	// Jettons are credited through the owner's jetton wallet and read by the
+	// relayer itself.
+	ParseResult(msg *tlb.InternalMessage) (*SwapResult, error)
+}
+
+// Migrator is implemented by launchpad adapters whose tokens graduate from a
+// bonding curve to a DEX pool.
+type Migrator interface {
+	// MigratedTo returns the platform the token now trades on, or "" while
+	// it is still on the curve.
+	MigratedTo(ctx context.Context, token *address.Address) (string, error)
+}
+
+// ErrUnrecognizedResult is returned by ParseResult for unrelated messages.
+var ErrUnrecognizedResult = errors.New("relayer: unrecognized swap result")
+
+// Registry maps platform names to adapters.
+type Registry struct {
+	mu       sync.RWMutex
+	adapters map[string]DEXAdapter
+}
+
+// NewRegistry creates a registry pre-populated with adapters.
+func NewRegistry(adapters ...DEXAdapter) *Registry {
+	reg := &Registry{adapters: make(map[string]DEXAdapter)}
+	for _, a := range adapters {
+		reg.Register(a)
+	}
+	return reg
+}
+
+// Register adds or replaces the adapter for a.Name().
+func (r *Registry) Register(a DEXAdapter) {
+	if a == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adapters[strings.ToLower(a.Name())] = a
+}
+
+// Get looks up the adapter for platform.
+func (r *Registry) Get(platform string) (DEXAdapter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.adapters[strings.ToLower(strings.TrimSpace(platform))]
+	return a, ok
+}
+
+// Platforms lists registered platform names.
+func (r *Registry) Platforms() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.adapters))
+	for name := range r.adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Jetton payout opcodes shared by every venue.
+const (
+	opJettonTransferNotification = 0x7362d09c
+	opJettonInternalTransfer     = 0x178d4519
+)
+
+// parseJettonPayout reads the query id and jetton amount from a
+// transfer_notification sent to the owner or an internal_transfer received by
+// its jetton wallet.
+func parseJettonPayout(msg *tlb.InternalMessage) (*SwapResult, error) {
+	op, queryID, body, ok := readOp(msg)
+	if !ok || (op != opJettonTransferNotification && op != opJettonInternalTransfer) {
+		return nil, ErrUnrecognizedResult
+	}
+	amount, err := body.LoadBigCoins()
+	if err != nil {
+		return nil, ErrUnrecognizedResult
+	}
+	return &SwapResult{QueryID: queryID, AmountOut: amount}, nil
+}
+
+// readOp reads the op and query id every payout body starts with.
+func readOp(msg *tlb.InternalMessage) (op, queryID uint64, body *cell.Slice, ok bool) {
+	if msg == nil || msg.Body == nil {
+		return 0, 0, nil, false
+	}
+	body = msg.Body.BeginParse()
+	var err error
+	if op, err = body.LoadUInt(32); err != nil {
+		return 0, 0, nil, false
+	}
+	if queryID, err = body.LoadUInt(64); err != nil {
+		return 0, 0, nil, false
+	}
+	return op, queryID, body, true
+}
@@ -0,0 +1,569 @@
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+// getMethodFixture is one runGetMethod exchange in Toncenter's wire format:
+// the request's address, method and stack, and the result it answers with.
+type getMethodFixture struct {
+	Address string            `json:"address"`
+	Method  string            `json:"method"`
+	Stack   []json.RawMessage `json:"stack"`
+	Result  json.RawMessage   `json:"result"`
+}
+
+// fixtureChain serves testdata/getmethods/<name>.json through a real
+// ton.Client, so adapters run the same stack decoding as in production. A
+// request without a matching fixture fails the test.
+func fixtureChain(t *testing.T, name string) ChainReader {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "getmethods", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []getMethodFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req getMethodFixture
+		if r.URL.Path != "/runGetMethod" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		for _, f := range fixtures {
+			if sameAccount(f.Address, req.Address) && f.Method == req.Method && sameStack(f.Stack, req.Stack) {
+				_, _ = w.Write([]byte(`{"ok":true,"result":` + string(f.Result) + `}`))
+				return
+			}
+		}
+		t.Errorf("%s: no fixture for %s on %s with stack %s", name, req.Method, req.Address, req.Stack)
+		_, _ = w.Write([]byte(`{"ok":false,"error":"no fixture"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return ton.NewClient(ton.Config{Endpoint: srv.URL + "/jsonrpc"})
+}
+
+func sameAccount(a, b string) bool {
+	pa, errA := address.ParseAddr(a)
+	pb, errB := address.ParseAddr(b)
+	return errA == nil && errB == nil && pa.StringRaw() == pb.StringRaw()
+}
+
+func sameStack(a, b []json.RawMessage) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// testAddr derives a stable basechain address for a fixture account.
+func testAddr(name string) *address.Address {
+	hash := sha256.Sum256([]byte(name))
+	return address.NewAddress(0, 0, hash[:])
+}
+
+var (
+	testOwner        = testAddr("owner")
+	testOwnerJettons = testAddr("owner jetton wallet")
+	testToken        = address.MustParseAddr("EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs")
+)
+
+const testQueryID = 0x5eed
+
+func nano(n int64) *big.Int { return big.NewInt(n) }
+
+// bodyReader walks a message body, failing the test on malformed cells.
+type bodyReader struct {
+	t *testing.T
+	s *cell.Slice
+}
+
+func readBody(t *testing.T, c *cell.Cell) *bodyReader {
+	t.Helper()
+	if c == nil {
+		t.Fatal("message has no body")
+	}
+	return &bodyReader{t: t, s: c.BeginParse()}
+}
+
+func (r *bodyReader) uint(bits uint) uint64 {
+	r.t.Helper()
+	v, err := r.s.LoadUInt(bits)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return v
+}
+
+func (r *bodyReader) coins() *big.Int {
+	r.t.Helper()
+	v, err := r.s.LoadBigCoins()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return v
+}
+
+func (r *bodyReader) addr() *address.Address {
+	r.t.Helper()
+	v, err := r.s.LoadAddr()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return v
+}
+
+func (r *bodyReader) bit() bool {
+	r.t.Helper()
+	v, err := r.s.LoadBoolBit()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return v
+}
+
+func (r *bodyReader) ref() *bodyReader {
+	r.t.Helper()
+	v, err := r.s.LoadRef()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return &bodyReader{t: r.t, s: v}
+}
+
+func (r *bodyReader) end() {
+	r.t.Helper()
+	if r.s.BitsLeft() != 0 || r.s.RefsNum() != 0 {
+		r.t.Errorf("body has %d unread bits and %d refs", r.s.BitsLeft(), r.s.RefsNum())
+	}
+}
+
+func (r *bodyReader) expectOp(op uint64) {
+	r.t.Helper()
+	if got := r.uint(32); got != op {
+		r.t.Fatalf("op = %#x, want %#x", got, op)
+	}
+}
+
+func (r *bodyReader) expectQueryID() {
+	r.t.Helper()
+	if got := r.uint(64); got != testQueryID {
+		r.t.Errorf("query id = %#x, want %#x", got, testQueryID)
+	}
+}
+
+func (r *bodyReader) expectCoins(field string, want *big.Int) {
+	r.t.Helper()
+	if got := r.coins(); got.Cmp(want) != 0 {
+		r.t.Errorf("%s = %s, want %s", field, got, want)
+	}
+}
+
+func (r *bodyReader) expectAddr(field string, want *address.Address) {
+	r.t.Helper()
+	got := r.addr()
+	if want == nil {
+		if got.Type() != address.NoneAddress {
+			r.t.Errorf("%s = %s, want none", field, got)
+		}
+		return
+	}
+	if !got.Equals(want) {
+		r.t.Errorf("%s = %s, want %s", field, got, want)
+	}
+}
+
+func (r *bodyReader) expectNoRef(field string) {
+	r.t.Helper()
+	if r.bit() {
+		r.t.Errorf("%s is set, want none", field)
+	}
+}
+
+// expectDeadline reads a unix deadline of bits width and checks it is about
+// ttl from now.
+func (r *bodyReader) expectDeadline(bits uint, ttl time.Duration) {
+	r.t.Helper()
+	got := time.Unix(int64(r.uint(bits)), 0)
+	if want := time.Now().Add(ttl); got.Before(want.Add(-time.Minute)) || got.After(want.Add(time.Second)) {
+		r.t.Errorf("deadline = %s, want about %s", got, want)
+	}
+}
+
+// expectJettonTransfer checks a TEP-74 transfer body and returns its forward
+// payload.
+func (r *bodyReader) expectJettonTransfer(amount *big.Int, dest, response *address.Address, forwardTon *big.Int) *bodyReader {
+	r.t.Helper()
+	r.expectOp(ton.OpJettonTransfer)
+	r.expectQueryID()
+	r.expectCoins("jetton amount", amount)
+	r.expectAddr("destination", dest)
+	r.expectAddr("response destination", response)
+	r.expectNoRef("custom payload")
+	r.expectCoins("forward ton", forwardTon)
+	if !r.bit() {
+		r.t.Fatal("forward payload is inline, want a ref")
+	}
+	payload := r.ref()
+	r.end()
+	return payload
+}
+
+func expectMessage(t *testing.T, msgs []*wallet.Message, dst *address.Address, value *big.Int) *bodyReader {
+	t.Helper()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	msg := msgs[0].InternalMessage
+	if msgs[0].Mode != wallet.PayGasSeparately+wallet.IgnoreErrors {
+		t.Errorf("mode = %d", msgs[0].Mode)
+	}
+	if !msg.Bounce {
+		t.Error("message is not bounceable")
+	}
+	if !msg.DstAddr.Equals(dst) {
+		t.Errorf("destination = %s, want %s", msg.DstAddr, dst)
+	}
+	if got := msg.Amount.Nano(); got.Cmp(value) != 0 {
+		t.Errorf("value = %s, want %s", got, value)
+	}
+	return readBody(t, msg.Body)
+}
+
+// swapCase is one quote + build round of an adapter against its fixtures.
+type swapCase struct {
+	name      string
+	direction string
+	token     *address.Address
+	amountIn  *big.Int
+	minOut    *big.Int
+
+	wantOut  *big.Int
+	wantTon  *big.Int
+	wantPool *address.Address
+	// checkBody asserts the built message and its body.
+	checkBody func(t *testing.T, msgs []*wallet.Message)
+}
+
+func runSwapCases(t *testing.T, adapter DEXAdapter, cases []swapCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			req := SwapRequest{
+				QueryID:   testQueryID,
+				Direction: tc.direction,
+				Owner:     testOwner,
+				Token:     tc.token,
+				AmountIn:  tc.amountIn,
+				MinOut:    tc.minOut,
+			}
+			if tc.direction == "sell" {
+				req.JettonWallet = testOwnerJettons
+			}
+			quote, err := adapter.Quote(ctx, req)
+			if err != nil {
+				t.Fatalf("Quote: %v", err)
+			}
+			if quote.AmountOut.Cmp(tc.wantOut) != 0 {
+				t.Errorf("quote out = %s, want %s", quote.AmountOut, tc.wantOut)
+			}
+			if quote.TonRequired.Cmp(tc.wantTon) != 0 {
+				t.Errorf("quote ton = %s, want %s", quote.TonRequired, tc.wantTon)
+			}
+			if !quote.Pool.Equals(tc.wantPool) {
+				t.Errorf("quote pool = %s, want %s", quote.Pool, tc.wantPool)
+			}
+			msgs, err := adapter.BuildSwap(ctx, req, quote)
+			if err != nil {
+				t.Fatalf("BuildSwap: %v", err)
+			}
+			tc.checkBody(t, msgs)
+		})
+	}
+}
+
+func TestDedustAdapter(t *testing.T) {
+	var (
+		nativeVault = testAddr("dedust native vault")
+		jettonVault = testAddr("dedust jetton vault")
+		pool        = testAddr("dedust pool")
+	)
+	dedustParams := func(r *bodyReader) {
+		p := r.ref()
+		p.expectDeadline(32, dedustSwapDeadline)
+		p.expectAddr("recipient", testOwner)
+		p.expectAddr("referral", nil)
+		p.expectNoRef("fulfill payload")
+		p.expectNoRef("reject payload")
+		p.end()
+	}
+	runSwapCases(t, NewDedustAdapter(fixtureChain(t, "dedust")), []swapCase{
+		{
+			name: "buy", direction: "buy", token: testToken,
+			amountIn: nano(1_000_000_000), minOut: nano(4_700_000),
+			wantOut: nano(4_950_000), wantTon: nano(1_200_000_000), wantPool: pool,
+			checkBody: func(t *testing.T, msgs []*wallet.Message) {
+				body := expectMessage(t, msgs, nativeVault, nano(1_200_000_000))
+				body.expectOp(dedustOpNativeSwap)
+				body.expectQueryID()
+				body.expectCoins("amount", nano(1_000_000_000))
+				body.expectAddr("pool", pool)
+				if body.uint(1) != 0 {
+					t.Error("swap kind is not given_in")
+				}
+				body.expectCoins("limit", nano(4_700_000))
+				body.expectNoRef("next step")
+				dedustParams(body)
+				body.end()
+			},
+		},
+		{
+			name: "sell", direction: "sell", token: testToken,
+			amountIn: nano(5_000_000), minOut: nano(940_000_000),
+			wantOut: nano(990_000_000), wantTon: nano(250_000_000), wantPool: pool,
+			checkBody: func(t *testing.T, msgs []*wallet.Message) {
+				body := expectMessage(t, msgs, testOwnerJettons, nano(250_000_000))
+				payload := body.expectJettonTransfer(nano(5_000_000), jettonVault, testOwner, nano(150_000_000))
+				payload.expectOp(dedustOpJettonSwap)
+				payload.expectAddr("pool", pool)
+				if payload.uint(1) != 0 {
+					t.Error("swap kind is not given_in")
+				}
+				payload.expectCoins("limit", nano(940_000_000))
+				payload.expectNoRef("next step")
+				dedustParams(payload)
+				payload.end()
+			},
+		},
+	})
+}
+
+func TestDedustAdapterMissingPool(t *testing.T) {
+	adapter := NewDedustAdapter(fixtureChain(t, "dedust"))
+	_, err := adapter.Quote(context.Background(), SwapRequest{
+		Direction: "buy",
+		Owner:     testOwner,
+		Token:     testAddr("unlisted jetton"),
+		AmountIn:  nano(1_000_000_000),
+	})
+	if !errors.Is(err, errPoolNotFound) {
+		t.Fatalf("Quote error = %v, want errPoolNotFound", err)
+	}
+}
+
+// TestStonfiV1Adapter routes a token that only has a v1 pool; its v2 pool
+// address is derived but not deployed.
+func TestStonfiV1Adapter(t *testing.T) {
+	var (
+		token       = testAddr("stonfi v1 jetton")
+		router      = address.MustParseAddr(stonfiV1Router)
+		ptonWallet  = testAddr("stonfi v1 pton wallet")
+		tokenWallet = testAddr("stonfi v1 token wallet")
+		pool        = testAddr("stonfi v1 pool")
+	)
+	swapPayload := func(r *bodyReader, ask *address.Address, minOut *big.Int) {
+		r.expectOp(stonfiOpSwapV1)
+		r.expectAddr("ask wallet", ask)
+		r.expectCoins("min out", minOut)
+		r.expectAddr("recipient", testOwner)
+		if r.bit() {
+			t.Error("referral is set")
+		}
+		r.end()
+	}
+	adapter, err := NewStonfiAdapter(fixtureChain(t, "stonfi"), StonfiOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runSwapCases(t, adapter, []swapCase{
+		{
+			name: "buy", direction: "buy", token: token,
+			amountIn: nano(1_000_000_000), minOut: nano(4_650_000),
+			wantOut: nano(4_900_000), wantTon: nano(1_215_000_000), wantPool: pool,
+			checkBody: func(t *testing.T, msgs []*wallet.Message) {
+				body := expectMessage(t, msgs, ptonWallet, nano(1_215_000_000))
+				payload := body.expectJettonTransfer(nano(1_000_000_000), router, testOwner, nano(215_000_000))
+				swapPayload(payload, tokenWallet, nano(4_650_000))
+			},
+		},
+		{
+			name: "sell", direction: "sell", token: token,
+			amountIn: nano(5_000_000), minOut: nano(930_000_000),
+			wantOut: nano(980_000_000), wantTon: nano(170_000_000), wantPool: pool,
+			checkBody: func(t *testing.T, msgs []*wallet.Message) {
+				body := expectMessage(t, msgs, testOwnerJettons, nano(170_000_000))
+				payload := body.expectJettonTransfer(nano(5_000_000), router, testOwner, nano(125_000_000))
+				swapPayload(payload, ptonWallet, nano(930_000_000))
+			},
+		},
+	})
+}
+
+// TestStonfiV2Adapter routes a token with pools on both routers to v2, which
+// quotes more in both directions.
+func TestStonfiV2Adapter(t *testing.T) {
+	var (
+		router      = address.MustParseAddr(stonfiV2Router)
+		ptonWallet  = testAddr("stonfi v2 pton wallet")
+		tokenWallet = testAddr("stonfi v2 token wallet")
+		pool        = testAddr("stonfi v2 pool")
+	)
+	swapPayload := func(r *bodyReader, ask *address.Address, minOut *big.Int) {
+		r.expectOp(stonfiOpSwapV2)
+		r.expectAddr("ask wallet", ask)
+		r.expectAddr("refund address", testOwner)
+		r.expectAddr("excesses address", testOwner)
+		r.expectDeadline(64, stonfiSwapDeadline)
+		params := r.ref()
+		r.end()
+		params.expectCoins("min out", minOut)
+		params.expectAddr("receiver", testOwner)
+		params.expectCoins("forward gas", nano(0))
+		params.expectNoRef("custom payload")
+		params.expectCoins("refund forward gas", nano(0))
+		params.expectNoRef("refund payload")
+		if fee := params.uint(16); fee != 10 {
+			t.Errorf("ref fee = %d, want 10", fee)
+		}
+		params.expectAddr("referral", nil)
+		params.end()
+	}
+	adapter, err := NewStonfiAdapter(fixtureChain(t, "stonfi"), StonfiOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runSwapCases(t, adapter, []swapCase{
+		{
+			name: "buy", direction: "buy", token: testToken,
+			amountIn: nano(1_000_000_000), minOut: nano(4_650_000),
+			wantOut: nano(4_910_000), wantTon: nano(1_300_000_000), wantPool: pool,
+			checkBody: func(t *testing.T, msgs []*wallet.Message) {
+				body := expectMessage(t, msgs, ptonWallet, nano(1_300_000_000))
+				body.expectOp(stonfiOpPtonTransferV2)
+				body.expectQueryID()
+				body.expectCoins("ton amount", nano(1_000_000_000))
+				body.expectAddr("refund address", testOwner)
+				if !body.bit() {
+					t.Fatal("swap payload is missing")
+				}
+				payload := body.ref()
+				body.end()
+				swapPayload(payload, tokenWallet, nano(4_650_000))
+			},
+		},
+		{
+			name: "sell", direction: "sell", token: testToken,
+			amountIn: nano(5_000_000), minOut: nano(930_000_000),
+			wantOut: nano(985_000_000), wantTon: nano(300_000_000), wantPool: pool,
+			checkBody: func(t *testing.T, msgs []*wallet.Message) {
+				body := expectMessage(t, msgs, testOwnerJettons, nano(300_000_000))
+				payload := body.expectJettonTransfer(nano(5_000_000), router, testOwner, nano(240_000_000))
+				swapPayload(payload, ptonWallet, nano(930_000_000))
+			},
+		},
+	})
+}
+
+func launchpad(t *testing.T, chain ChainReader, name string) DEXAdapter {
+	t.Helper()
+	for _, adapter := range NewLaunchpadAdapters(chain) {
+		if adapter.Name() == name {
+			return adapter
+		}
+	}
+	t.Fatalf("no launchpad adapter %q", name)
+	return nil
+}
+
+func TestCurveAdapters(t *testing.T) {
+	chain := fixtureChain(t, "curve")
+	var (
+		tonfunToken   = testAddr("tonfun jetton")
+		gaspumpToken  = testAddr("gaspump jetton")
+		migratedToken = testAddr("gaspump migrated jetton")
+	)
+	curveBody := func(op uint64, amountIn *big.Int, minOut *big.Int) func(t *testing.T, body *bodyReader) {
+		return func(t *testing.T, body *bodyReader) {
+			body.expectOp(op)
+			body.expectQueryID()
+			if amountIn != nil {
+				body.expectCoins("amount", amountIn)
+			}
+			body.expectCoins("min out", minOut)
+			body.expectNoRef("referral")
+			body.end()
+		}
+	}
+	t.Run("tonfun", func(t *testing.T) {
+		check := func(dst *address.Address, value *big.Int, body func(*testing.T, *bodyReader)) func(*testing.T, []*wallet.Message) {
+			return func(t *testing.T, msgs []*wallet.Message) {
+				body(t, expectMessage(t, msgs, dst, value))
+			}
+		}
+		runSwapCases(t, launchpad(t, chain, "tonfun"), []swapCase{
+			{
+				name: "buy", direction: "buy", token: tonfunToken,
+				amountIn: nano(1_000_000_000), minOut: nano(117_000_000_000),
+				wantOut: nano(123_456_789_000), wantTon: nano(1_100_000_000), wantPool: tonfunToken,
+				checkBody: check(tonfunToken, nano(1_100_000_000), curveBody(0xaf750d34, nil, nano(117_000_000_000))),
+			},
+			{
+				name: "sell", direction: "sell", token: tonfunToken,
+				amountIn: nano(100_000_000_000), minOut: nano(760_000_000),
+				wantOut: nano(800_000_000), wantTon: nano(150_000_000), wantPool: tonfunToken,
+				checkBody: check(testOwnerJettons, nano(150_000_000), curveBody(0x742b36d8, nano(100_000_000_000), nano(760_000_000))),
+			},
+		})
+	})
+	t.Run("gaspump", func(t *testing.T) {
+		adapter := launchpad(t, chain, "gaspump")
+		quote, err := adapter.Quote(context.Background(), SwapRequest{Direction: "buy", Token: gaspumpToken, AmountIn: nano(1_000_000_000)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := nano(777_000_000_000); quote.AmountOut.Cmp(want) != 0 {
+			t.Errorf("quote out = %s, want %s", quote.AmountOut, want)
+		}
+		_, err = adapter.Quote(context.Background(), SwapRequest{Direction: "sell", Token: migratedToken, AmountIn: nano(5_000_000)})
+		if !errors.Is(err, errCurveClosed) {
+			t.Errorf("quote on a closed curve error = %v, want errCurveClosed", err)
+		}
+	})
+	t.Run("migration", func(t *testing.T) {
+		ctx := context.Background()
+		tests := []struct {
+			launchpad string
+			token     *address.Address
+			want      string
+		}{
+			{"tonfun", tonfunToken, ""},
+			{"gaspump", gaspumpToken, ""},
+			{"gaspump", migratedToken, "dedust"},
+		}
+		for _, tt := range tests {
+			dex, err := launchpad(t, chain, tt.launchpad).(Migrator).MigratedTo(ctx, tt.token)
+			if err != nil || dex != tt.want {
+				t.Errorf("%s MigratedTo(%s) = %q, %v; want %q", tt.launchpad, tt.token, dex, err, tt.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xssnick/tonutils-go/ton/wallet"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+// defaultBatchSize matches the four messages a V4R2 wallet accepts in one
+// external message.
+const defaultBatchSize = 4
+
+// executeBatch signs the claimed orders of one wallet into a single external
+// message and broadcasts it. Orders that cannot be built fail or retry on
+// their own without holding back the rest. The message hash is persisted
+// before broadcast so a crashed attempt can be checked on-chain; once that
+// happened the orders move to sent even if the broadcast call itself failed,
+// because the message may still have been accepted.
+func (r *SwapRelayer) executeBatch(ctx context.Context, orders []database.SwapOrder) error {
+	contract, err := r.loadWallet(ctx, &orders[0])
+	if err != nil {
+		return r.failOrders(ctx, orders, err)
+	}
+	available, err := r.tonBalance(ctx, contract.WalletAddress())
+	if err != nil {
+		return r.failOrders(ctx, orders, err)
+	}
+	var legs []*swapLeg
+	messages, maxMessages := 0, ton.MaxMessages(contract)
+	for i := range orders {
+		order := &orders[i]
+		leg, err := r.buildLeg(ctx, order, contract.WalletAddress(), available)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			if err := r.handleFailure(ctx, order, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if messages+len(leg.msgs) > maxMessages && len(legs) > 0 {
+			if _, err := r.opts.Store.UnclaimSwapOrder(ctx, order.ID, r.workerID); err != nil {
+				return err
+			}
+			r.log("swap order %d does not fit the batch, requeued", order.ID)
+			continue
+		}
+		available.Sub(available, leg.tonRequired)
+		messages += len(leg.msgs)
+		legs = append(legs, leg)
+	}
+	if len(legs) == 0 {
+		return nil
+	}
+
+	// Signing happens under the orders' row locks so a concurrent cancel
+	// either wins before anything is signed or sees the recorded message.
+	// The wallet state is read first: nothing inside the locks may wait on
+	// the network.
+	state, err := r.opts.TonClient.LoadWalletState(ctx, contract)
+	if err != nil {
+		return r.failLegs(ctx, legs, err)
+	}
+	signed := make([]database.SignedSwapOrder, len(legs))
+	for i, leg := range legs {
+		signed[i] = database.SignedSwapOrder{
+			ID:         leg.order.ID,
+			AmountIn:   leg.amountIn.String(),
+			OutMsgHash: ton.BodyHash(leg.msgs[0].InternalMessage.Body),
+		}
+	}
+	var prepared *ton.PreparedMessage
+	held, err := r.opts.Store.SignSwapOrders(ctx, r.workerID, signed, func(held []int64) (database.UpdateSwapOrderOptions, error) {
+		var msgs []*wallet.Message
+		for _, leg := range heldLegs(legs, held) {
+			msgs = append(msgs, leg.msgs...)
+		}
+		var err error
+		if prepared, err = r.opts.TonClient.SignMessages(ctx, contract, state, msgs); err != nil {
+			return database.UpdateSwapOrderOptions{}, err
+		}
+		return database.UpdateSwapOrderOptions{
+			MsgHash:    &prepared.Hash,
+			SentAt:     &prepared.SentAt,
+			ValidUntil: &prepared.ValidUntil,
+		}, nil
+	})
+	if err != nil && !errors.Is(err, database.ErrSwapOrderReleased) {
+		return r.failLegs(ctx, legs, err)
+	}
+	included := heldLegs(legs, held)
+	if len(included) < len(legs) {
+		for _, leg := range legs {
+			if !containsID(held, leg.order.ID) {
+				// Canceled (or reaped) while executing; nothing was signed.
+				r.log("swap order %d released before signing, skipped", leg.order.ID)
+			}
+		}
+	}
+	if len(included) == 0 {
+		return nil
+	}
+
+	sent, err := r.opts.TonClient.SendPrepared(ctx, prepared)
+	if err != nil {
+		// The outcome of the broadcast is unknown; confirmation decides.
+		r.log("swap message %s broadcast error, awaiting confirmation: %v", prepared.Hash, err)
+		sent = &prepared.SentMessage
+	}
+	for _, leg := range included {
+		if _, err := r.opts.Store.UpdateSwapOrderStatus(ctx, leg.order.ID, "sent", database.UpdateSwapOrderOptions{
+			MsgHash:    &sent.Hash,
+			SentAt:     &sent.SentAt,
+			ValidUntil: &sent.ValidUntil,
+		}); err != nil {
+			return err
+		}
+		r.log("swap order %d sent (msg %s, %d in batch)", leg.order.ID, sent.Hash, len(included))
+	}
+	r.stats.broadcast()
+	return nil
+}
+
+// failOrders routes a failure that hit the whole batch to every order.
+func (r *SwapRelayer) failOrders(ctx context.Context, orders []database.SwapOrder, cause error) error {
+	if errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	for i := range orders {
+		if err := r.handleFailure(ctx, &orders[i], cause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failLegs routes a failure that hit the whole message to every leg.
+func (r *SwapRelayer) failLegs(ctx context.Context, legs []*swapLeg, cause error) error {
+	if errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	for _, leg := range legs {
+		if err := r.handleFailure(ctx, leg.order, cause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func heldLegs(legs []*swapLeg, held []int64) []*swapLeg {
+	var out []*swapLeg
+	for _, leg := range legs {
+		if containsID(held, leg.order.ID) {
+			out = append(out, leg)
+		}
+	}
+	return out
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+const (
+	confirmInterval  = 5 * time.Second
+	confirmBatchSize = 100
+)
+
+var (
+	errMessageExpired = errors.New("message_expired")
+	errTxFailed       = errors.New("tx_failed")
+	errMessageSkipped = errors.New("message_skipped")
+)
+
+// confirmLoop follows sent orders until their external message shows up in
+// the wallet's transactions or can no longer be accepted, then settles the
+// fills of confirmed ones.
+func (r *SwapRelayer) confirmLoop(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(confirmInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.closing:
+			return
+		case <-ticker.C:
+		}
+		if err := r.confirmSent(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.log("confirmation error: %v", err)
+		}
+		if err := r.settleFills(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.log("fill settlement error: %v", err)
+		}
+	}
+}
+
+func (r *SwapRelayer) confirmSent(ctx context.Context) error {
+	orders, err := r.opts.Store.ListSentSwapOrders(ctx, confirmBatchSize)
+	if err != nil {
+		return err
+	}
+	// Orders batched into one external message share its transaction.
+	found := make(map[string]*ton.TxResult)
+	for i := range orders {
+		if err := r.confirmOrder(ctx, &orders[i], found); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			r.log("confirm order %d: %v", orders[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *SwapRelayer) confirmOrder(ctx context.Context, order *database.SwapOrder, found map[string]*ton.TxResult) error {
+	if order.MsgHash == nil || order.SentAt == nil || order.ValidUntil == nil {
+		return r.finishOrder(ctx, order, nil, errors.New("missing_broadcast_details"))
+	}
+	wallet, err := r.opts.Store.GetWalletByID(ctx, order.WalletID)
+	if err != nil {
+		return err
+	}
+	if wallet == nil {
+		return r.finishOrder(ctx, order, nil, errWalletNotFound)
+	}
+	owner, err := address.ParseAddr(wallet.Address)
+	if err != nil {
+		return err
+	}
+	res, ok := found[*order.MsgHash]
+	if !ok {
+		if res, err = r.opts.TonClient.FindMessageTransaction(ctx, owner, *order.MsgHash, *order.SentAt); err != nil {
+			return err
+		}
+		found[*order.MsgHash] = res
+	}
+	if res == nil {
+		if ton.Expired(*order.ValidUntil) {
+			// The message can no longer land, so resending is safe.
+			return r.handleFailure(ctx, order, errMessageExpired)
+		}
+		return nil
+	}
+	if !res.Success() {
+		return r.finishOrder(ctx, order, res, fmt.Errorf("%w: exit_code=%d action_result_code=%d", errTxFailed, res.ComputeExitCode, res.ActionResultCode))
+	}
+	if order.OutMsgHash != nil && !res.Emitted(*order.OutMsgHash) {
+		// The wallet skipped this order's message, e.g. when funds ran out
+		// partway through a batch. Nothing left for it, so it can run again.
+		return r.handleFailure(ctx, order, errMessageSkipped)
+	}
+	return r.finishOrder(ctx, order, res, nil)
+}
+
+// finishOrder records the final state of a sent order: confirmed when
+// failure is nil, failed otherwise.
+func (r *SwapRelayer) finishOrder(ctx context.Context, order *database.SwapOrder, res *ton.TxResult, failure error) error {
+	status := "confirmed"
+	var opts database.UpdateSwapOrderOptions
+	if res != nil {
+		exitCode, actionCode, lt := int64(res.ComputeExitCode), int64(res.ActionResultCode), int64(res.LT)
+		opts.TxHash = &res.Hash
+		opts.TxLT = &lt
+		opts.ExitCode = &exitCode
+		opts.ActionCode = &actionCode
+	}
+	if failure != nil {
+		status = "failed"
+		msg := formatError(failure)
+		opts.Error = &msg
+	}
+	if _, err := r.opts.Store.UpdateSwapOrderStatus(ctx, order.ID, status, opts); err != nil {
+		return err
+	}
+	if failure != nil {
+		r.stats.failure(order.Platform, formatError(failure))
+		r.log("swap order %d failed on-chain: %v", order.ID, failure)
+	} else {
+		r.stats.success()
+		r.log("swap order %d confirmed (tx %s)", order.ID, res.Hash)
+	}
+	// The wallet is free again; its next queued order can run now.
+	r.wakeWorkers()
+	return nil
+}
@@ -0,0 +1,212 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+var (
+	curveBuyGas  = big.NewInt(100_000_000) // 0.1 TON on top of the buy amount
+	curveSellGas = big.NewInt(150_000_000) // 0.15 TON attached to the sell
+)
+
+var errCurveClosed = errors.New("curve_trading_closed")
+
+// curveSpec describes one launchpad's bonding-curve jetton. Buys are sent to
+// the jetton master with TON attached; sells are sent to the owner's jetton
+// wallet, which burns the jettons and has the master pay out TON.
+type curveSpec struct {
+	name   string
+	buyOp  uint64
+	sellOp uint64
+	// buyQuote / sellQuote are master get-methods taking the input amount;
+	// the expected output sits at quoteIndex of the result.
+	buyQuote   string
+	sellQuote  string
+	quoteIndex int
+	// state is the master get-method whose result tells whether the curve
+	// still trades; trading reads it.
+	state   string
+	trading func(*ton.GetMethodResult) (bool, error)
+	// dex is the platform tokens trade on once the curve closes.
+	dex string
+}
+
+// bclTrading reads tradingEnabled from get_bcl_data.
+func bclTrading(res *ton.GetMethodResult) (bool, error) {
+	flag, err := res.Int(11)
+	if err != nil {
+		return false, err
+	}
+	return flag.Sign() != 0, nil
+}
+
+// gaspumpTrading reads tradeState from getFullJettonData; 0 is the curve.
+func gaspumpTrading(res *ton.GetMethodResult) (bool, error) {
+	state, err := res.Int(3)
+	if err != nil {
+		return false, err
+	}
+	return state.Sign() == 0, nil
+}
+
+func bclSpec(name, dex string) curveSpec {
+	return curveSpec{
+		name:       name,
+		buyOp:      0xaf750d34,
+		sellOp:     0x742b36d8,
+		buyQuote:   "coins_for_tons",
+		sellQuote:  "tons_for_coins",
+		quoteIndex: 1, // (fees, amount)
+		state:      "get_bcl_data",
+		trading:    bclTrading,
+		dex:        dex,
+	}
+}
+
+// launchpadSpecs lists the bonding-curve launchpads the watcher recognizes.
+var launchpadSpecs = []curveSpec{
+	bclSpec("tonfun", "stonfi"),
+	bclSpec("memeslab", "stonfi"),
+	bclSpec("blum", "stonfi"),
+	{
+		name:       "gaspump",
+		buyOp:      0x6cd3e4b0,
+		sellOp:     0x595f07bc,
+		buyQuote:   "getBuyAmount",
+		sellQuote:  "getSellAmount",
+		quoteIndex: 0,
+		state:      "getFullJettonData",
+		trading:    gaspumpTrading,
+		dex:        "dedust",
+	},
+}
+
+// curveAdapter trades a launchpad's bonding-curve jettons.
+type curveAdapter struct {
+	chain ChainReader
+	spec  curveSpec
+
+	mu       sync.Mutex
+	migrated map[string]bool
+}
+
+// NewLaunchpadAdapters returns adapters for every supported launchpad.
+func NewLaunchpadAdapters(chain ChainReader) []DEXAdapter {
+	adapters := make([]DEXAdapter, 0, len(launchpadSpecs))
+	for _, spec := range launchpadSpecs {
+		adapters = append(adapters, &curveAdapter{chain: chain, spec: spec, migrated: make(map[string]bool)})
+	}
+	return adapters
+}
+
+// Name implements DEXAdapter.
+func (a *curveAdapter) Name() string {
+	return a.spec.name
+}
+
+// MigratedTo implements Migrator. Migration is one-way, so a closed curve
+// is remembered and not queried again.
+func (a *curveAdapter) MigratedTo(ctx context.Context, token *address.Address) (string, error) {
+	key := token.String()
+	a.mu.Lock()
+	done := a.migrated[key]
+	a.mu.Unlock()
+	if done {
+		return a.spec.dex, nil
+	}
+	res, err := a.chain.RunGetMethod(ctx, key, a.spec.state)
+	if err != nil {
+		return "", fmt.Errorf("%s state: %w", a.spec.name, err)
+	}
+	trading, err := a.spec.trading(res)
+	if err != nil {
+		return "", fmt.Errorf("%s state: %w", a.spec.name, err)
+	}
+	if trading {
+		return "", nil
+	}
+	a.mu.Lock()
+	a.migrated[key] = true
+	a.mu.Unlock()
+	return a.spec.dex, nil
+}
+
+// Quote implements DEXAdapter.
+func (a *curveAdapter) Quote(ctx context.Context, req SwapRequest) (*Quote, error) {
+	method, tonRequired := a.spec.buyQuote, new(big.Int).Add(req.AmountIn, curveBuyGas)
+	if req.Direction == "sell" {
+		method, tonRequired = a.spec.sellQuote, new(big.Int).Set(curveSellGas)
+	}
+	res, err := a.chain.RunGetMethod(ctx, req.Token.String(), method, ton.NumArg(req.AmountIn))
+	if err != nil {
+		if errors.Is(err, ton.ErrGetMethodFailed) {
+			return nil, errCurveClosed
+		}
+		return nil, fmt.Errorf("%s quote: %w", a.spec.name, err)
+	}
+	out, err := res.Int(a.spec.quoteIndex)
+	if err != nil {
+		return nil, fmt.Errorf("%s quote: %w", a.spec.name, err)
+	}
+	return &Quote{AmountIn: req.AmountIn, AmountOut: out, TonRequired: tonRequired, Pool: req.Token}, nil
+}
+
+// BuildSwap implements DEXAdapter.
+func (a *curveAdapter) BuildSwap(_ context.Context, req SwapRequest, _ *Quote) ([]*wallet.Message, error) {
+	minOut := req.MinOut
+	if minOut == nil {
+		minOut = big.NewInt(0)
+	}
+	if req.Direction == "sell" {
+		if req.JettonWallet == nil {
+			return nil, errors.New("jetton wallet required for sell")
+		}
+		body := cell.BeginCell().
+			MustStoreUInt(a.spec.sellOp, 32).
+			MustStoreUInt(req.QueryID, 64).
+			MustStoreBigCoins(req.AmountIn).
+			MustStoreBigCoins(minOut).
+			MustStoreMaybeRef(nil). // referral
+			EndCell()
+		return []*wallet.Message{curveMessage(req.JettonWallet, curveSellGas, body)}, nil
+	}
+	body := cell.BeginCell().
+		MustStoreUInt(a.spec.buyOp, 32).
+		MustStoreUInt(req.QueryID, 64).
+		MustStoreBigCoins(minOut).
+		MustStoreMaybeRef(nil). // referral
+		EndCell()
+	value := new(big.Int).Add(req.AmountIn, curveBuyGas)
+	return []*wallet.Message{curveMessage(req.Token, value, body)}, nil
+}
+
+// ParseResult implements DEXAdapter. Curve buys mint jettons to the owner,
+// but sell proceeds arrive as plain TON transfers from the master without the
+// query id, so no TON payout can be tied to an order.
+func (a *curveAdapter) ParseResult(*tlb.InternalMessage) (*SwapResult, error) {
+	return nil, ErrUnrecognizedResult
+}
+
+func curveMessage(dst *address.Address, value *big.Int, body *cell.Cell) *wallet.Message {
+	return &wallet.Message{
+		Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+		InternalMessage: &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      true,
+			DstAddr:     dst,
+			Amount:      tlb.FromNanoTON(value),
+			Body:        body,
+		},
+	}
+}
@@ -0,0 +1,282 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+// DeDust mainnet factory, see https://docs.dedust.io/reference/factory.
+const dedustFactoryAddr = "EQBfBWT7X2BHg9tXAxzhz2aKiNTU1tpt5NsiK0uSDW_YAJ67"
+
+const (
+	dedustOpNativeSwap = 0xea06185d // swap on the native vault
+	dedustOpJettonSwap = 0xe3a0d482 // forward payload for jetton vault swaps
+	dedustOpPayout     = 0x474f86cf // native vault payout to the recipient
+	dedustPoolVolatile = 0
+	dedustSwapDeadline = 120 * time.Second
+)
+
+var (
+	dedustNativeSwapGas       = big.NewInt(200_000_000) // 0.2 TON
+	dedustJettonForwardTon    = big.NewInt(150_000_000) // 0.15 TON
+	dedustJettonTransferValue = big.NewInt(250_000_000) // 0.25 TON
+)
+
+var errPoolNotFound = errors.New("pool_not_found")
+
+// dedustAdapter resolves DeDust vaults/pools and builds swap messages.
+type dedustAdapter struct {
+	chain   ChainReader
+	factory *address.Address
+
+	mu           sync.Mutex
+	nativeVault  *address.Address
+	jettonVaults map[string]*address.Address
+	pools        map[string]*address.Address
+}
+
+// NewDedustAdapter returns the DeDust volatile-pool adapter.
+func NewDedustAdapter(chain ChainReader) DEXAdapter {
+	return &dedustAdapter{
+		chain:        chain,
+		factory:      address.MustParseAddr(dedustFactoryAddr),
+		jettonVaults: make(map[string]*address.Address),
+		pools:        make(map[string]*address.Address),
+	}
+}
+
+func dedustNativeAsset() *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(0b0000, 4).EndCell()
+}
+
+func dedustJettonAsset(token *address.Address) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(0b0001, 4).
+		MustStoreInt(int64(token.Workchain()), 8).
+		MustStoreSlice(token.Data(), 256).
+		EndCell()
+}
+
+func (d *dedustAdapter) nativeVaultAddress(ctx context.Context) (*address.Address, error) {
+	d.mu.Lock()
+	cached := d.nativeVault
+	d.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	res, err := d.chain.RunGetMethod(ctx, d.factory.String(), "get_vault", ton.SliceArg(dedustNativeAsset()))
+	if err != nil {
+		return nil, fmt.Errorf("dedust native vault: %w", err)
+	}
+	addr, err := res.Address(0)
+	if err != nil {
+		return nil, fmt.Errorf("dedust native vault: %w", err)
+	}
+	d.mu.Lock()
+	d.nativeVault = addr
+	d.mu.Unlock()
+	return addr, nil
+}
+
+func (d *dedustAdapter) jettonVaultAddress(ctx context.Context, token *address.Address) (*address.Address, error) {
+	key := token.String()
+	d.mu.Lock()
+	cached := d.jettonVaults[key]
+	d.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	res, err := d.chain.RunGetMethod(ctx, d.factory.String(), "get_vault", ton.SliceArg(dedustJettonAsset(token)))
+	if err != nil {
+		return nil, fmt.Errorf("dedust jetton vault: %w", err)
+	}
+	addr, err := res.Address(0)
+	if err != nil {
+		return nil, fmt.Errorf("dedust jetton vault: %w", err)
+	}
+	d.mu.Lock()
+	d.jettonVaults[key] = addr
+	d.mu.Unlock()
+	return addr, nil
+}
+
+// poolAddress returns the volatile TON/jetton pool. Assets are ordered the same
+// way the DeDust SDK sorts them ("jetton:..." before "native").
+func (d *dedustAdapter) poolAddress(ctx context.Context, token *address.Address) (*address.Address, error) {
+	key := token.String()
+	d.mu.Lock()
+	cached := d.pools[key]
+	d.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	res, err := d.chain.RunGetMethod(ctx, d.factory.String(), "get_pool",
+		ton.NumArg(big.NewInt(dedustPoolVolatile)),
+		ton.SliceArg(dedustJettonAsset(token)),
+		ton.SliceArg(dedustNativeAsset()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dedust pool: %w", err)
+	}
+	addr, err := res.Address(0)
+	if err != nil {
+		return nil, fmt.Errorf("dedust pool: %w", err)
+	}
+	d.mu.Lock()
+	d.pools[key] = addr
+	d.mu.Unlock()
+	return addr, nil
+}
+
+// estimateSwapOut asks the pool how much of the other asset amountIn yields.
+func (d *dedustAdapter) estimateSwapOut(ctx context.Context, pool *address.Address, assetIn *cell.Cell, amountIn *big.Int) (*big.Int, error) {
+	res, err := d.chain.RunGetMethod(ctx, pool.String(), "get_estimated_swap_out", ton.SliceArg(assetIn), ton.NumArg(amountIn))
+	if err != nil {
+		if errors.Is(err, ton.ErrGetMethodFailed) {
+			return nil, errPoolNotFound
+		}
+		return nil, err
+	}
+	return res.Int(1)
+}
+
+func dedustSwapParams(recipient *address.Address) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(uint64(time.Now().Add(dedustSwapDeadline).Unix()), 32).
+		MustStoreAddr(recipient).
+		MustStoreAddr(nil). // referral
+		MustStoreMaybeRef(nil).
+		MustStoreMaybeRef(nil).
+		EndCell()
+}
+
+func dedustSwapStep(b *cell.Builder, pool *address.Address, minOut *big.Int) *cell.Builder {
+	return b.MustStoreAddr(pool).
+		MustStoreUInt(0, 1). // given_in
+		MustStoreBigCoins(minOut).
+		MustStoreMaybeRef(nil)
+}
+
+// Name implements DEXAdapter.
+func (d *dedustAdapter) Name() string {
+	return "dedust"
+}
+
+// Quote implements DEXAdapter.
+func (d *dedustAdapter) Quote(ctx context.Context, req SwapRequest) (*Quote, error) {
+	pool, err := d.poolAddress(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	assetIn := dedustNativeAsset()
+	tonRequired := new(big.Int).Add(req.AmountIn, dedustNativeSwapGas)
+	if req.Direction == "sell" {
+		assetIn = dedustJettonAsset(req.Token)
+		tonRequired = new(big.Int).Set(dedustJettonTransferValue)
+	}
+	out, err := d.estimateSwapOut(ctx, pool, assetIn, req.AmountIn)
+	if err != nil {
+		return nil, err
+	}
+	return &Quote{AmountIn: req.AmountIn, AmountOut: out, TonRequired: tonRequired, Pool: pool}, nil
+}
+
+// BuildSwap implements DEXAdapter.
+func (d *dedustAdapter) BuildSwap(ctx context.Context, req SwapRequest, quote *Quote) ([]*wallet.Message, error) {
+	minOut := req.MinOut
+	if minOut == nil {
+		minOut = big.NewInt(0)
+	}
+	var msg *wallet.Message
+	var err error
+	if req.Direction == "sell" {
+		msg, err = d.buildSell(ctx, req.QueryID, req.Owner, req.JettonWallet, req.Token, quote.Pool, req.AmountIn, minOut)
+	} else {
+		msg, err = d.buildBuy(ctx, req.QueryID, req.Owner, quote.Pool, req.AmountIn, minOut)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*wallet.Message{msg}, nil
+}
+
+// ParseResult implements DEXAdapter. The native vault pays TON out with a
+// payout carrying the swap's query id: the proceeds of a sell, or the input
+// of a buy the pool refunded because min_out was not met.
+func (d *dedustAdapter) ParseResult(msg *tlb.InternalMessage) (*SwapResult, error) {
+	op, queryID, _, ok := readOp(msg)
+	if !ok || op != dedustOpPayout {
+		return nil, ErrUnrecognizedResult
+	}
+	return &SwapResult{QueryID: queryID, AmountOut: msg.Amount.Nano()}, nil
+}
+
+// buildBuy swaps amountIn nanoTON for jettons through the native vault.
+func (d *dedustAdapter) buildBuy(ctx context.Context, queryID uint64, recipient, pool *address.Address, amountIn, minOut *big.Int) (*wallet.Message, error) {
+	vault, err := d.nativeVaultAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b := cell.BeginCell().
+		MustStoreUInt(dedustOpNativeSwap, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreBigCoins(amountIn)
+	body := dedustSwapStep(b, pool, minOut).
+		MustStoreRef(dedustSwapParams(recipient)).
+		EndCell()
+	value := new(big.Int).Add(amountIn, dedustNativeSwapGas)
+	return &wallet.Message{
+		Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+		InternalMessage: &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      true,
+			DstAddr:     vault,
+			Amount:      tlb.FromNanoTON(value),
+			Body:        body,
+		},
+	}, nil
+}
+
+// buildSell transfers jettons from the owner's jetton wallet into the jetton vault
+// with a swap forward payload.
+func (d *dedustAdapter) buildSell(ctx context.Context, queryID uint64, owner, jettonWallet, token, pool *address.Address, amountIn, minOut *big.Int) (*wallet.Message, error) {
+	vault, err := d.jettonVaultAddress(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	payload := dedustSwapStep(cell.BeginCell().MustStoreUInt(dedustOpJettonSwap, 32), pool, minOut).
+		MustStoreRef(dedustSwapParams(owner)).
+		EndCell()
+	body, err := ton.BuildJettonTransferBody(ton.JettonTransferParams{
+		QueryID:         queryID,
+		Amount:          amountIn,
+		Destination:     vault,
+		ResponseAddress: owner,
+		ForwardTon:      dedustJettonForwardTon,
+		ForwardPayload:  payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet.Message{
+		Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+		InternalMessage: &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      true,
+			DstAddr:     jettonWallet,
+			Amount:      tlb.FromNanoTON(dedustJettonTransferValue),
+			Body:        body,
+		},
+	}, nil
+}
@@ -0,0 +1,184 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+const (
+	settleBatchSize = 50
+	// settleTimeout gives up on locating the payout of a confirmed order.
+	settleTimeout = 15 * time.Minute
+)
+
+// settleFills reads the payouts of confirmed orders back from the chain and
+// applies them to positions. The wallet transaction only proves the swap
+// request left the wallet; the DEX pays out (or refunds) in later messages.
+func (r *SwapRelayer) settleFills(ctx context.Context) error {
+	orders, err := r.opts.Store.ListUnsettledSwapOrders(ctx, settleBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		if err := r.settleOrder(ctx, &orders[i]); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			r.log("settle order %d: %v", orders[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// orderInflow sums what the venue delivered to the owner for one order.
+type orderInflow struct {
+	jettons *big.Int // jettons of the order's token: bought, or a sell refunded
+	ton     *big.Int // TON: sell proceeds, or a buy refunded
+}
+
+func (r *SwapRelayer) settleOrder(ctx context.Context, order *database.SwapOrder) error {
+	// updated_at is the confirmation time: nothing else touches a confirmed
+	// order before it settles.
+	age := time.Since(order.UpdatedAt)
+	if order.AmountIn == nil {
+		return r.applyFill(ctx, order, database.FillStatusUnknown, nil, nil)
+	}
+	amountIn, ok := new(big.Int).SetString(strings.TrimSpace(*order.AmountIn), 10)
+	if !ok || amountIn.Sign() <= 0 {
+		return r.applyFill(ctx, order, database.FillStatusUnknown, nil, nil)
+	}
+	wallet, err := r.opts.Store.GetWalletByID(ctx, order.WalletID)
+	if err != nil {
+		return err
+	}
+	if wallet == nil {
+		return r.applyFill(ctx, order, database.FillStatusUnknown, nil, nil)
+	}
+	owner, err := address.ParseAddr(wallet.Address)
+	if err != nil {
+		return err
+	}
+	token, err := address.ParseAddr(strings.TrimSpace(order.TokenAddress))
+	if err != nil {
+		return r.applyFill(ctx, order, database.FillStatusUnknown, nil, nil)
+	}
+	inflow, err := r.orderInflow(ctx, order, owner, token)
+	if err != nil {
+		return err
+	}
+	status, jettons, nanoTon, ok := inflow.fill(order.Direction, amountIn)
+	switch {
+	case ok && status == database.FillStatusFilled:
+		return r.settleAmounts(ctx, order, token, jettons, nanoTon)
+	case ok:
+		return r.applyFill(ctx, order, status, nil, nil)
+	case age >= settleTimeout:
+		return r.applyFill(ctx, order, database.FillStatusUnknown, nil, nil)
+	}
+	return nil
+}
+
+// fill decides the outcome of an order that spent amountIn. A sell is filled
+// by TON proceeds (less any jettons sent back) and refunded when all its
+// jettons come back; a buy is filled by jettons and refunded when its TON
+// comes back. ok is false while neither has arrived.
+func (in *orderInflow) fill(direction string, amountIn *big.Int) (status string, jettons, nanoTon *big.Int, ok bool) {
+	if direction == "sell" {
+		sold := new(big.Int).Sub(amountIn, in.jettons)
+		switch {
+		case sold.Sign() <= 0:
+			return database.FillStatusRefunded, nil, nil, true
+		case in.ton.Sign() > 0:
+			return database.FillStatusFilled, sold, in.ton, true
+		}
+		return "", nil, nil, false
+	}
+	switch {
+	case in.jettons.Sign() > 0:
+		return database.FillStatusFilled, in.jettons, amountIn, true
+	case in.ton.Sign() > 0:
+		return database.FillStatusRefunded, nil, nil, true
+	}
+	return "", nil, nil, false
+}
+
+// orderInflow collects the messages the owner received after the order's
+// transaction that carry the order's query id. Jettons
+// count only when credited by the owner's wallet for the order's token; TON
+// counts only when the venue adapter recognizes the message as its payout.
+func (r *SwapRelayer) orderInflow(ctx context.Context, order *database.SwapOrder, owner, token *address.Address) (*orderInflow, error) {
+	inflow := &orderInflow{jettons: big.NewInt(0), ton: big.NewInt(0)}
+	if order.TxLT == nil {
+		return inflow, nil
+	}
+	adapter, ok := r.adapters.Get(order.Platform)
+	if !ok {
+		return inflow, nil
+	}
+	jettonWallet, err := r.opts.TonClient.GetJettonWalletAddress(ctx, token, owner)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := r.opts.TonClient.ListInboundMessages(ctx, owner, uint64(*order.TxLT))
+	if err != nil {
+		return nil, err
+	}
+	queryID := orderQueryID(order)
+	horizon := order.UpdatedAt.Add(settleTimeout)
+	for _, in := range msgs {
+		if in.At.After(horizon) {
+			break
+		}
+		msg := in.Msg
+		if msg == nil || msg.Bounced {
+			continue
+		}
+		if msg.SrcAddr != nil && msg.SrcAddr.Equals(jettonWallet) {
+			if res, err := parseJettonPayout(msg); err == nil && res.QueryID == queryID {
+				inflow.jettons.Add(inflow.jettons, res.AmountOut)
+			}
+			continue
+		}
+		if res, err := adapter.ParseResult(msg); err == nil && res.QueryID == queryID {
+			inflow.ton.Add(inflow.ton, res.AmountOut)
+		}
+	}
+	return inflow, nil
+}
+
+// settleAmounts converts raw fill amounts into whole tokens and TON.
+func (r *SwapRelayer) settleAmounts(ctx context.Context, order *database.SwapOrder, token *address.Address, jettons, nanoTon *big.Int) error {
+	decimals, err := r.tokenDecimals(ctx, token)
+	if err != nil {
+		return err
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	tokens := new(big.Rat).SetFrac(jettons, unit).FloatString(decimals)
+	tonAmount := new(big.Rat).SetFrac(nanoTon, big.NewInt(1_000_000_000)).FloatString(9)
+	return r.applyFill(ctx, order, database.FillStatusFilled, &tokens, &tonAmount)
+}
+
+func (r *SwapRelayer) applyFill(ctx context.Context, order *database.SwapOrder, status string, tokens, tonAmount *string) error {
+	applied, err := r.opts.Store.ApplySwapFill(ctx, database.SwapFill{
+		OrderID: order.ID,
+		Status:  status,
+		Tokens:  tokens,
+		Ton:     tonAmount,
+	})
+	if err != nil || !applied {
+		return err
+	}
+	if status == database.FillStatusFilled {
+		r.log("swap order %d filled: %s tokens for %s TON", order.ID, *tokens, *tonAmount)
+	} else {
+		r.log("swap order %d settled as %s", order.ID, status)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package relayer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+// inboxTon serves the owner's inbound messages and its jetton wallet, and
+// panics on any other call.
+type inboxTon struct {
+	TonService
+	jettonWallet *address.Address
+	inbox        []ton.InboundMessage
+}
+
+func (f *inboxTon) GetJettonWalletAddress(context.Context, *address.Address, *address.Address) (*address.Address, error) {
+	return f.jettonWallet, nil
+}
+
+func (f *inboxTon) ListInboundMessages(context.Context, *address.Address, uint64) ([]ton.InboundMessage, error) {
+	return f.inbox, nil
+}
+
+func inbound(src *address.Address, value *big.Int, body *cell.Cell) ton.InboundMessage {
+	return ton.InboundMessage{
+		At: time.Now(),
+		Msg: &tlb.InternalMessage{
+			SrcAddr: src,
+			DstAddr: testOwner,
+			Amount:  tlb.FromNanoTON(value),
+			Body:    body,
+		},
+	}
+}
+
+func payoutBody(op, queryID uint64, amount *big.Int) *cell.Cell {
+	b := cell.BeginCell().MustStoreUInt(op, 32).MustStoreUInt(queryID, 64)
+	if amount != nil {
+		b.MustStoreBigCoins(amount)
+	}
+	return b.EndCell()
+}
+
+// jettonCredit is a transfer_notification from the owner's jetton wallet.
+func jettonCredit(from *address.Address, queryID uint64, amount *big.Int) ton.InboundMessage {
+	return inbound(from, nano(1), payoutBody(opJettonTransferNotification, queryID, amount))
+}
+
+func testOrder(id int64, direction, platform string) *database.SwapOrder {
+	lt := int64(1)
+	return &database.SwapOrder{
+		ID:           id,
+		Direction:    direction,
+		Platform:     platform,
+		TokenAddress: testToken.String(),
+		TxLT:         &lt,
+		UpdatedAt:    time.Now(),
+	}
+}
+
+func TestOrderInflowMatchesQueryID(t *testing.T) {
+	stonfi, err := NewStonfiAdapter(nil, StonfiOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		nativeVault = testAddr("dedust native vault")
+		ptonWallet  = testAddr("stonfi pton wallet")
+		otherJetton = testAddr("owner other jetton wallet")
+	)
+	chain := &inboxTon{jettonWallet: testOwnerJettons, inbox: []ton.InboundMessage{
+		// buy 7 and buy 8 of the same token, paid out in order
+		jettonCredit(testOwnerJettons, 7, nano(100)),
+		jettonCredit(testOwnerJettons, 8, nano(50)),
+		// another token credited under an order's query id
+		jettonCredit(otherJetton, 7, nano(1_000)),
+		// sell 9 paid out by the DeDust native vault, then buy 10 lands
+		inbound(nativeVault, nano(2_000_000_000), payoutBody(dedustOpPayout, 9, nil)),
+		jettonCredit(testOwnerJettons, 10, nano(500)),
+		// buy 11 refunded by the native vault
+		inbound(nativeVault, nano(900_000_000), payoutBody(dedustOpPayout, 11, nil)),
+		// STON.fi sell 12: proxy-TON proceeds, plus a jetton notification
+		// from elsewhere that carries no TON
+		inbound(ptonWallet, nano(1_500_000_000), payoutBody(opJettonTransferNotification, 12, nano(1_400_000_000))),
+		inbound(otherJetton, nano(1), payoutBody(opJettonTransferNotification, 12, nano(7_000))),
+		// excesses and plain transfers carry no payout
+		inbound(nativeVault, nano(50_000_000), payoutBody(0xd53276db, 9, nil)),
+		inbound(testAddr("friend"), nano(3_000_000_000), nil),
+	}}
+	r := New(Options{TonClient: chain, Adapters: NewRegistry(NewDedustAdapter(nil), stonfi)})
+
+	tests := []struct {
+		order       *database.SwapOrder
+		wantJettons int64
+		wantTon     int64
+	}{
+		{testOrder(7, "buy", "dedust"), 100, 0},
+		{testOrder(8, "buy", "dedust"), 50, 0},
+		{testOrder(9, "sell", "dedust"), 0, 2_000_000_000},
+		{testOrder(10, "buy", "dedust"), 500, 0},
+		{testOrder(11, "buy", "dedust"), 0, 900_000_000},
+		{testOrder(12, "sell", "stonfi"), 0, 1_400_000_000},
+		{testOrder(13, "sell", "dedust"), 0, 0},
+	}
+	for _, tt := range tests {
+		inflow, err := r.orderInflow(context.Background(), tt.order, testOwner, testToken)
+		if err != nil {
+			t.Fatalf("order %d: %v", tt.order.ID, err)
+		}
+		if inflow.jettons.Cmp(nano(tt.wantJettons)) != 0 || inflow.ton.Cmp(nano(tt.wantTon)) != 0 {
+			t.Errorf("order %d: jettons %s ton %s, want %d and %d", tt.order.ID, inflow.jettons, inflow.ton, tt.wantJettons, tt.wantTon)
+		}
+	}
+}
+
+func TestOrderInflowFill(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		jettons   int64
+		ton       int64
+
+		wantStatus  string
+		wantJettons int64
+		wantTon     int64
+	}{
+		{"buy filled", "buy", 500, 0, database.FillStatusFilled, 500, 1_000},
+		{"buy refunded", "buy", 0, 990, database.FillStatusRefunded, 0, 0},
+		{"buy pending", "buy", 0, 0, "", 0, 0},
+		{"sell filled", "sell", 0, 2_000, database.FillStatusFilled, 1_000, 2_000},
+		{"sell partly refunded", "sell", 400, 1_200, database.FillStatusFilled, 600, 1_200},
+		{"sell refunded", "sell", 1_000, 0, database.FillStatusRefunded, 0, 0},
+		{"sell pending", "sell", 0, 0, "", 0, 0},
+		{"sell partial refund pending", "sell", 400, 0, "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &orderInflow{jettons: nano(tt.jettons), ton: nano(tt.ton)}
+			status, jettons, nanoTon, ok := in.fill(tt.direction, nano(1_000))
+			if ok != (tt.wantStatus != "") || status != tt.wantStatus {
+				t.Fatalf("fill = %q, %v; want %q", status, ok, tt.wantStatus)
+			}
+			if status != database.FillStatusFilled {
+				return
+			}
+			if jettons.Cmp(nano(tt.wantJettons)) != 0 || nanoTon.Cmp(nano(tt.wantTon)) != 0 {
+				t.Errorf("fill amounts = %s jettons for %s, want %d for %d", jettons, nanoTon, tt.wantJettons, tt.wantTon)
+			}
+		})
+	}
+}
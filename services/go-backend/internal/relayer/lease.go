@@ -0,0 +1,144 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+const (
+	defaultLeaseDuration = 60 * time.Second
+	reapInterval         = 30 * time.Second
+	reapBatchSize        = 50
+)
+
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "relayer"
+	}
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// holdLease heartbeats the leases on orders until the returned func is called.
+func (r *SwapRelayer) holdLease(ctx context.Context, orders []database.SwapOrder) func() {
+	hbCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.opts.LeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			for i := range orders {
+				order := &orders[i]
+				ok, err := r.opts.Store.ExtendSwapOrderLease(hbCtx, order.ID, r.workerID, r.opts.LeaseDuration)
+				if err != nil {
+					if !errors.Is(err, context.Canceled) {
+						r.log("swap order %d heartbeat: %v", order.ID, err)
+					}
+					continue
+				}
+				if !ok {
+					r.log("swap order %d lease lost", order.ID)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// reapLoop recovers orders whose worker stopped heartbeating.
+func (r *SwapRelayer) reapLoop(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.closing:
+			return
+		case <-ticker.C:
+		}
+		if err := r.reapExpired(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.log("reaper error: %v", err)
+		}
+	}
+}
+
+func (r *SwapRelayer) reapExpired(ctx context.Context) error {
+	orders, err := r.opts.Store.ListExpiredLeases(ctx, reapBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		if err := r.reapOrder(ctx, &orders[i]); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			r.log("reap order %d: %v", orders[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// reapOrder requeues an orphaned order only once it is certain its message
+// never reached the wallet: either nothing was signed, or the signed message
+// is absent on-chain and past valid_until.
+func (r *SwapRelayer) reapOrder(ctx context.Context, order *database.SwapOrder) error {
+	if order.MsgHash == nil {
+		return r.reap(ctx, order, "queued")
+	}
+	if order.SentAt == nil || order.ValidUntil == nil {
+		return fmt.Errorf("message %s without send window", *order.MsgHash)
+	}
+	wallet, err := r.opts.Store.GetWalletByID(ctx, order.WalletID)
+	if err != nil {
+		return err
+	}
+	if wallet == nil {
+		return errWalletNotFound
+	}
+	owner, err := address.ParseAddr(wallet.Address)
+	if err != nil {
+		return err
+	}
+	res, err := r.opts.TonClient.FindMessageTransaction(ctx, owner, *order.MsgHash, *order.SentAt)
+	if err != nil {
+		return err
+	}
+	if res != nil {
+		// Landed: hand it to the confirmation loop to record the outcome.
+		return r.reap(ctx, order, "sent")
+	}
+	if !ton.Expired(*order.ValidUntil) {
+		return nil
+	}
+	return r.reap(ctx, order, "queued")
+}
+
+func (r *SwapRelayer) reap(ctx context.Context, order *database.SwapOrder, status string) error {
+	ok, err := r.opts.Store.ReapSwapOrder(ctx, order.ID, status)
+	if err != nil {
+		return err
+	}
+	if ok {
+		r.log("swap order %d lease expired, moved to %s", order.ID, status)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+var errInvalidLimitPrice = errors.New("invalid_limit_price")
+
+// limitBatchSize caps how many waiting orders one price pass re-quotes.
+const limitBatchSize = 100
+
+var nanoPerTon = big.NewRat(1_000_000_000, 1)
+
+func (r *SwapRelayer) priceLoop(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.opts.PriceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.closing:
+			return
+		case <-ticker.C:
+		}
+		if err := r.checkLimitOrders(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.log("limit check error: %v", err)
+		}
+		if err := r.checkTriggers(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.log("trigger check error: %v", err)
+		}
+		if err := r.checkTrailingStops(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.log("trailing stop check error: %v", err)
+		}
+	}
+}
+
+// checkLimitOrders expires stale limit orders and queues the ones whose
+// price has crossed the limit.
+func (r *SwapRelayer) checkLimitOrders(ctx context.Context) error {
+	expired, err := r.opts.Store.ExpireSwapOrders(ctx)
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		r.log("%d limit orders expired", expired)
+	}
+	orders, err := r.opts.Store.ListWaitingSwapOrders(ctx, limitBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		order := &orders[i]
+		hit, err := r.limitReached(ctx, order)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			if !isOrderRejection(err) {
+				r.log("limit order %d: %v", order.ID, err)
+				continue
+			}
+			msg := formatError(err)
+			if _, err := r.opts.Store.UpdateSwapOrderStatus(ctx, order.ID, "failed", database.UpdateSwapOrderOptions{
+				Error: &msg,
+			}); err != nil {
+				return err
+			}
+			r.log("limit order %d rejected: %s", order.ID, msg)
+			continue
+		}
+		if !hit {
+			continue
+		}
+		ok, err := r.opts.Store.ActivateSwapOrder(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.log("limit order %d triggered at limit %s", order.ID, *order.LimitPrice)
+		}
+	}
+	return nil
+}
+
+// limitReached quotes the order at the current pool price. Buys trigger when
+// the TON-per-token price drops to the limit, sells when it rises to it.
+func (r *SwapRelayer) limitReached(ctx context.Context, order *database.SwapOrder) (bool, error) {
+	adapter, err := r.orderAdapter(ctx, order)
+	if err != nil {
+		return false, err
+	}
+	wallet, err := r.opts.Store.GetWalletByID(ctx, order.WalletID)
+	if err != nil {
+		return false, err
+	}
+	if wallet == nil {
+		return false, errWalletNotFound
+	}
+	owner, err := address.ParseAddr(wallet.Address)
+	if err != nil {
+		return false, err
+	}
+	req, err := r.swapRequest(ctx, order, owner)
+	if err != nil {
+		return false, err
+	}
+	minOut, err := r.limitMinOut(ctx, order, req)
+	if err != nil {
+		return false, err
+	}
+	quote, err := adapter.Quote(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	return quote.AmountOut != nil && quote.AmountOut.Sign() > 0 && quote.AmountOut.Cmp(minOut) >= 0, nil
+}
+
+// limitMinOut converts the order's limit price (TON per whole token) into the
+// minimum output the swap must return.
+func (r *SwapRelayer) limitMinOut(ctx context.Context, order *database.SwapOrder, req SwapRequest) (*big.Int, error) {
+	if order.LimitPrice == nil {
+		return big.NewInt(0), nil
+	}
+	limit, ok := new(big.Rat).SetString(strings.TrimSpace(*order.LimitPrice))
+	if !ok || limit.Sign() <= 0 {
+		return nil, errInvalidLimitPrice
+	}
+	decimals, err := r.tokenDecimals(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	unit := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	amountIn := new(big.Rat).SetInt(req.AmountIn)
+	var out *big.Rat
+	if req.Direction == "sell" {
+		// tokens / 10^dec * limit TON, in nanoTON
+		out = new(big.Rat).Quo(amountIn, unit)
+		out.Mul(out, limit).Mul(out, nanoPerTon)
+	} else {
+		// nanoTON / 1e9 / limit tokens, in token units
+		out = new(big.Rat).Quo(amountIn, nanoPerTon)
+		out.Quo(out, limit).Mul(out, unit)
+	}
+	minOut := new(big.Int).Quo(out.Num(), out.Denom())
+	if minOut.Sign() <= 0 {
+		return nil, errInvalidLimitPrice
+	}
+	return minOut, nil
+}
+
+func (r *SwapRelayer) tokenDecimals(ctx context.Context, token *address.Address) (int, error) {
+	key := token.String()
+	r.decimalsMu.Lock()
+	dec, ok := r.decimals[key]
+	r.decimalsMu.Unlock()
+	if ok {
+		return dec, nil
+	}
+	dec, err := r.opts.TonClient.GetJettonDecimals(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	r.decimalsMu.Lock()
+	r.decimals[key] = dec
+	r.decimalsMu.Unlock()
+	return dec, nil
+}
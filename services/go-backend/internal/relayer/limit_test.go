@@ -0,0 +1,96 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+// decimalsTon serves jetton decimals and panics on any other call.
+type decimalsTon struct {
+	TonService
+	decimals map[string]int
+	calls    int
+}
+
+func (d *decimalsTon) GetJettonDecimals(_ context.Context, master *address.Address) (int, error) {
+	d.calls++
+	dec, ok := d.decimals[master.String()]
+	if !ok {
+		return 0, errors.New("unknown jetton")
+	}
+	return dec, nil
+}
+
+const (
+	testJetton6 = "EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs"
+	testJetton9 = "EQAvlWFDxGF2lXm67y4yzC17wYKD9A0guwPkMs1gOsM__NOT"
+)
+
+func TestLimitMinOut(t *testing.T) {
+	ton := &decimalsTon{decimals: map[string]int{
+		address.MustParseAddr(testJetton6).String(): 6,
+		address.MustParseAddr(testJetton9).String(): 9,
+	}}
+	r := New(Options{TonClient: ton, Adapters: NewRegistry()})
+	tests := []struct {
+		name      string
+		direction string
+		token     string
+		amountIn  string
+		limit     string
+		want      string
+	}{
+		// 2 TON at 0.5 TON per token buys at least 4 tokens of 6 decimals.
+		{"buy 6 decimals", "buy", testJetton6, "2000000000", "0.5", "4000000"},
+		{"buy 9 decimals", "buy", testJetton9, "2000000000", "0.5", "4000000000"},
+		// 4 tokens at 0.5 TON each sell for at least 2 TON.
+		{"sell 6 decimals", "sell", testJetton6, "4000000", "0.5", "2000000000"},
+		{"sell 9 decimals", "sell", testJetton9, "4000000000", "0.5", "2000000000"},
+		// Sub-nano prices keep full precision and round down.
+		{"buy fractional", "buy", testJetton9, "1000000000", "0.000000003", "333333333333333333"},
+		{"sell fractional", "sell", testJetton6, "1234567", "0.0015", "1851850"},
+	}
+	for _, tt := range tests {
+		amountIn, _ := new(big.Int).SetString(tt.amountIn, 10)
+		limit := tt.limit
+		order := &database.SwapOrder{LimitPrice: &limit}
+		req := SwapRequest{Direction: tt.direction, Token: address.MustParseAddr(tt.token), AmountIn: amountIn}
+		got, err := r.limitMinOut(context.Background(), order, req)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: limitMinOut = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if ton.calls != 2 {
+		t.Errorf("GetJettonDecimals called %d times, want once per token", ton.calls)
+	}
+}
+
+func TestLimitMinOutNoLimit(t *testing.T) {
+	r := New(Options{TonClient: &decimalsTon{}, Adapters: NewRegistry()})
+	got, err := r.limitMinOut(context.Background(), &database.SwapOrder{}, SwapRequest{})
+	if err != nil || got.Sign() != 0 {
+		t.Errorf("limitMinOut without a limit = %v, %v; want 0", got, err)
+	}
+}
+
+func TestLimitMinOutInvalid(t *testing.T) {
+	ton := &decimalsTon{decimals: map[string]int{address.MustParseAddr(testJetton9).String(): 9}}
+	r := New(Options{TonClient: ton, Adapters: NewRegistry()})
+	req := SwapRequest{Direction: "sell", Token: address.MustParseAddr(testJetton9), AmountIn: big.NewInt(1)}
+	for _, limit := range []string{"0", "-1", "abc", "1e-30"} {
+		_, err := r.limitMinOut(context.Background(), &database.SwapOrder{LimitPrice: &limit}, req)
+		if !errors.Is(err, errInvalidLimitPrice) {
+			t.Errorf("limit %q: error = %v, want errInvalidLimitPrice", limit, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const listenRetryDelay = 5 * time.Second
+
+// listenLoop turns swap order NOTIFYs into worker wake-ups. While the
+// listener is down the workers keep polling every stopDelay.
+func (r *SwapRelayer) listenLoop(ctx context.Context) {
+	defer r.wg.Done()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for ctx.Err() == nil {
+		if err := r.listen(ctx); err != nil && ctx.Err() == nil {
+			r.log("listen error: %v", err)
+			r.idle(ctx, listenRetryDelay)
+		}
+	}
+}
+
+func (r *SwapRelayer) listen(ctx context.Context) error {
+	listener, err := r.opts.Store.ListenSwapOrders(ctx)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	r.stats.listening.Store(true)
+	defer r.stats.listening.Store(false)
+	// Anything queued while the listener was down is picked up right away.
+	r.wakeWorkers()
+	for {
+		if _, err := listener.Wait(ctx); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+		r.wakeWorkers()
+	}
+}
+
+// wakeWorkers nudges idle workers without blocking; pending wake-ups
+// coalesce in the buffered channel.
+func (r *SwapRelayer) wakeWorkers() {
+	for i := 0; i < r.opts.Workers; i++ {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
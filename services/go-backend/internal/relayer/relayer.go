@@ -4,30 +4,84 @@ import (
 	"context"
 	"errors"
 	"log"
+	"math/big"
+	"sync"
 	"time"
 
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
 )
 
+// defaultWorkers is the executor pool size when Options.Workers is unset.
+const defaultWorkers = 4
+
 // Logger is a minimal logging interface used by the relayer.
 type Logger interface {
 	Printf(format string, v ...any)
 }
 
+// TonService captures the chain operations the relayer needs.
+type TonService interface {
+	ChainReader
+	GetAccountBalance(ctx context.Context, address string) (*ton.Balance, error)
+	GetJettonWalletBalance(ctx context.Context, jettonWallet *address.Address) (*big.Int, error)
+	GetJettonDecimals(ctx context.Context, master *address.Address) (int, error)
+	OpenWallet(mnemonic string, version ton.WalletVersion) (*wallet.Wallet, error)
+	LoadWalletState(ctx context.Context, contract *wallet.Wallet) (*ton.WalletState, error)
+	SignMessages(ctx context.Context, contract *wallet.Wallet, state *ton.WalletState, msgs []*wallet.Message) (*ton.PreparedMessage, error)
+	SendPrepared(ctx context.Context, prepared *ton.PreparedMessage) (*ton.SentMessage, error)
+	FindMessageTransaction(ctx context.Context, wallet *address.Address, msgHash string, since time.Time) (*ton.TxResult, error)
+	ListInboundMessages(ctx context.Context, wallet *address.Address, afterLT uint64) ([]ton.InboundMessage, error)
+}
+
 // Options configure SwapRelayer.
 type Options struct {
 	Store     *database.Store
+	TonClient TonService
 	Logger    Logger
 	MasterKey []byte
+	Stonfi    StonfiOptions
+	// Adapters overrides the default DeDust + STON.fi registry.
+	Adapters *Registry
+	// Workers is the number of concurrent order executors (default 4).
+	// Orders of one wallet never run concurrently regardless.
+	Workers int
+	// MaxAttempts bounds retries of retryable failures before an order is
+	// parked as dead (default 5).
+	MaxAttempts int
+	// LeaseDuration is how long a claimed order stays owned without a
+	// heartbeat before the reaper may recover it (default 60s).
+	LeaseDuration time.Duration
+	// PriceCheckInterval controls how often waiting limit orders are
+	// re-quoted (default 15s).
+	PriceCheckInterval time.Duration
+	// DefaultSlippage is the slippage percent applied to orders created
+	// without one (default 5).
+	DefaultSlippage float64
+	// BatchSize caps how many queued orders of one wallet are signed into
+	// a single external message (default 4). The wallet version may allow
+	// fewer messages, see ton.MaxMessages.
+	BatchSize int
 }
 
-// SwapRelayer polls swap_orders and will execute swaps (WIP).
+// SwapRelayer polls swap_orders and executes them through DEX adapters.
 type SwapRelayer struct {
 	opts      Options
+	workerID  string
+	adapters  *Registry
 	closing   chan struct{}
 	closed    chan struct{}
+	wake      chan struct{}
+	wg        sync.WaitGroup
+	stats     relayerStats
 	started   bool
 	stopDelay time.Duration
+
+	decimalsMu sync.Mutex
+	decimals   map[string]int
 }
 
 // New creates a new relayer instance.
@@ -36,12 +90,69 @@ func New(opts Options) *SwapRelayer {
 	if logger == nil {
 		logger = log.Default()
 	}
-	return &SwapRelayer{
-		opts:      Options{Store: opts.Store, Logger: logger, MasterKey: opts.MasterKey},
+	priceInterval := opts.PriceCheckInterval
+	if priceInterval <= 0 {
+		priceInterval = 15 * time.Second
+	}
+	lease := opts.LeaseDuration
+	if lease <= 0 {
+		lease = defaultLeaseDuration
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	slippage := opts.DefaultSlippage
+	if slippage <= 0 || slippage > 100 {
+		slippage = defaultSlippage
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = defaultBatchSize
+	}
+	r := &SwapRelayer{
+		opts: Options{
+			Store:              opts.Store,
+			TonClient:          opts.TonClient,
+			Logger:             logger,
+			MasterKey:          opts.MasterKey,
+			Stonfi:             opts.Stonfi,
+			Workers:            workers,
+			MaxAttempts:        maxAttempts,
+			LeaseDuration:      lease,
+			PriceCheckInterval: priceInterval,
+			DefaultSlippage:    slippage,
+			BatchSize:          batch,
+		},
+		workerID:  newWorkerID(),
+		adapters:  opts.Adapters,
 		closing:   make(chan struct{}),
 		closed:    make(chan struct{}),
+		wake:      make(chan struct{}, workers),
 		stopDelay: 2 * time.Second,
+		decimals:  make(map[string]int),
+	}
+	if r.adapters == nil {
+		r.adapters = NewRegistry(NewDedustAdapter(opts.TonClient))
+		for _, launchpad := range NewLaunchpadAdapters(opts.TonClient) {
+			r.adapters.Register(launchpad)
+		}
+		if stonfi, err := NewStonfiAdapter(opts.TonClient, opts.Stonfi); err != nil {
+			r.log("stonfi disabled: %v", err)
+		} else {
+			r.adapters.Register(stonfi)
+		}
 	}
+	return r
+}
+
+// Adapters exposes the platform registry so callers can plug in more venues.
+func (r *SwapRelayer) Adapters() *Registry {
+	return r.adapters
 }
 
 // Start launches the relayer loop.
@@ -50,7 +161,21 @@ func (r *SwapRelayer) Start(ctx context.Context) {
 		return
 	}
 	r.started = true
-	go r.loop(ctx)
+	r.stats.started()
+	r.log("swap relayer started with %d workers", r.opts.Workers)
+	r.wg.Add(r.opts.Workers + 4)
+	for i := 0; i < r.opts.Workers; i++ {
+		go r.loop(ctx)
+	}
+	go r.listenLoop(ctx)
+	go r.priceLoop(ctx)
+	go r.confirmLoop(ctx)
+	go r.reapLoop(ctx)
+	go func() {
+		r.wg.Wait()
+		close(r.closed)
+		r.log("swap relayer stopped")
+	}()
 }
 
 // Stop requests graceful shutdown.
@@ -63,12 +188,10 @@ func (r *SwapRelayer) Stop() {
 	<-r.closed
 }
 
+// loop is one worker: it claims orders back to back and, when nothing is due,
+// sleeps until a NOTIFY wake-up or the stopDelay fallback poll.
 func (r *SwapRelayer) loop(ctx context.Context) {
-	r.log("swap relayer started (Go prototype)")
-	defer func() {
-		close(r.closed)
-		r.log("swap relayer stopped")
-	}()
+	defer r.wg.Done()
 	for {
 		select {
 		case <-ctx.Done():
@@ -77,34 +200,57 @@ func (r *SwapRelayer) loop(ctx context.Context) {
 			return
 		default:
 		}
-		if err := r.processNext(ctx); err != nil {
+		processed, err := r.processNext(ctx)
+		if err != nil {
 			if !errors.Is(err, context.Canceled) {
 				r.log("relayer error: %v", err)
 			}
-			time.Sleep(3 * time.Second)
+			r.idle(ctx, 3*time.Second)
 			continue
 		}
-		time.Sleep(r.stopDelay)
+		if !processed {
+			r.idleUntilWake(ctx, r.stopDelay)
+		}
 	}
 }
 
-func (r *SwapRelayer) processNext(ctx context.Context) error {
-	order, err := r.opts.Store.ClaimNextSwapOrder(ctx)
-	if err != nil {
-		return err
+func (r *SwapRelayer) idleUntilWake(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-r.closing:
+	case <-r.wake:
+	case <-timer.C:
 	}
-	if order == nil {
-		return nil
+}
+
+func (r *SwapRelayer) idle(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-r.closing:
+	case <-timer.C:
 	}
+}
 
-	_, updErr := r.opts.Store.UpdateSwapOrderStatus(ctx, order.ID, "error", database.UpdateSwapOrderOptions{
-		Error: strPtr("not_implemented"),
-	})
-	if updErr != nil {
-		return updErr
+// processNext executes the next due batch of orders and reports whether
+// there was one.
+func (r *SwapRelayer) processNext(ctx context.Context) (bool, error) {
+	orders, err := r.opts.Store.ClaimSwapOrderBatch(ctx, r.workerID, r.opts.LeaseDuration, r.opts.BatchSize)
+	if err != nil {
+		return false, err
+	}
+	if len(orders) == 0 {
+		return false, nil
 	}
-	r.log("swap order %d marked as not implemented", order.ID)
-	return nil
+
+	r.stats.inFlight.Add(int64(len(orders)))
+	defer r.stats.inFlight.Add(-int64(len(orders)))
+	release := r.holdLease(ctx, orders)
+	defer release()
+	return true, r.executeBatch(ctx, orders)
 }
 
 func (r *SwapRelayer) log(format string, v ...any) {
@@ -113,6 +259,10 @@ func (r *SwapRelayer) log(format string, v ...any) {
 	}
 }
 
-func strPtr(s string) *string {
-	return &s
+func formatError(err error) string {
+	msg := err.Error()
+	if len(msg) > 240 {
+		msg = msg[:240]
+	}
+	return msg
 }
@@ -0,0 +1,89 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+const (
+	defaultMaxAttempts = 5
+	retryBaseDelay     = 5 * time.Second
+	retryMaxDelay      = 5 * time.Minute
+)
+
+// terminalErrors fail an order immediately: retrying cannot change the
+// outcome without user action.
+var terminalErrors = []error{
+	errInsufficientBalance,
+	errInsufficientFees,
+	errZeroAmountOut,
+	errPoolNotFound,
+	errDecryptFailed,
+	errTxFailed,
+}
+
+// isTerminal reports whether err should fail the order instead of retrying.
+// Anything unclassified (RPC timeouts, 5xx, seqno races) is retryable.
+func isTerminal(err error) bool {
+	if isOrderRejection(err) {
+		return true
+	}
+	for _, target := range terminalErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay is the exponential backoff before the next attempt.
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// handleFailure routes a failed attempt: limit orders that slipped past their
+// price go back to waiting, terminal errors fail the order, retryable ones are
+// rescheduled with backoff until the attempt budget is spent and the order
+// is parked as dead.
+func (r *SwapRelayer) handleFailure(ctx context.Context, order *database.SwapOrder, cause error) error {
+	msg := formatError(cause)
+	r.stats.failure(order.Platform, msg)
+	switch {
+	case errors.Is(cause, errPriceBeyondLimit) && order.LimitPrice != nil:
+		if _, err := r.opts.Store.RetrySwapOrder(ctx, order.ID, "waiting_price", time.Now(), msg); err != nil {
+			return err
+		}
+		r.log("swap order %d back to waiting_price: %s", order.ID, msg)
+	case isTerminal(cause):
+		if _, err := r.opts.Store.UpdateSwapOrderStatus(ctx, order.ID, "failed", database.UpdateSwapOrderOptions{
+			Error: &msg,
+		}); err != nil {
+			return err
+		}
+		r.log("swap order %d failed: %s", order.ID, msg)
+	case order.Attempts >= r.opts.MaxAttempts:
+		if _, err := r.opts.Store.UpdateSwapOrderStatus(ctx, order.ID, "dead", database.UpdateSwapOrderOptions{
+			Error: &msg,
+		}); err != nil {
+			return err
+		}
+		r.log("swap order %d dead after %d attempts: %s", order.ID, order.Attempts, msg)
+	default:
+		delay := retryDelay(order.Attempts)
+		if _, err := r.opts.Store.RetrySwapOrder(ctx, order.ID, "queued", time.Now().Add(delay), msg); err != nil {
+			return err
+		}
+		r.log("swap order %d attempt %d failed, retry in %s: %s", order.ID, order.Attempts, delay, msg)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package relayer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{6, 160 * time.Second},
+		{7, retryMaxDelay},
+		{100, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errInsufficientBalance, true},
+		{errInsufficientFees, true},
+		{errZeroAmountOut, true},
+		{errPoolNotFound, true},
+		{errDecryptFailed, true},
+		{errTxFailed, true},
+		{fmt.Errorf("quote: %w", errPoolNotFound), true},
+		// order rejections
+		{errInvalidPercent, true},
+		{errInvalidSlippage, true},
+		{fmt.Errorf("limit: %w", errInvalidLimitPrice), true},
+		// transient failures
+		{errors.New("ton request runGetMethod failed: status 502"), false},
+		{errMessageExpired, false},
+		{errPriceBeyondLimit, false},
+	}
+	for _, tt := range tests {
+		if got := isTerminal(tt.err); got != tt.want {
+			t.Errorf("isTerminal(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
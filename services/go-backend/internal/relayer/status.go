@@ -0,0 +1,123 @@
+package relayer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stallAfter is how long due orders may sit with no relayer activity before
+// Status reports the relayer as stalled.
+const stallAfter = 2 * time.Minute
+
+// Status is a point-in-time view of the relayer for health checks.
+type Status struct {
+	Running         bool             `json:"running"`
+	Stalled         bool             `json:"stalled"`
+	Listening       bool             `json:"listening"`
+	Workers         int              `json:"workers"`
+	InFlight        int64            `json:"in_flight"`
+	Queue           map[string]int64 `json:"queue"`
+	StartedAt       *time.Time       `json:"started_at,omitempty"`
+	LastBroadcastAt *time.Time       `json:"last_broadcast_at,omitempty"`
+	LastSuccessAt   *time.Time       `json:"last_success_at,omitempty"`
+	LastFailureAt   *time.Time       `json:"last_failure_at,omitempty"`
+	LastError       string           `json:"last_error,omitempty"`
+	PlatformErrors  map[string]int64 `json:"platform_errors"`
+}
+
+// relayerStats accumulates counters updated by workers and loops.
+type relayerStats struct {
+	inFlight  atomic.Int64
+	listening atomic.Bool
+
+	mu              sync.Mutex
+	startedAt       time.Time
+	lastBroadcastAt time.Time
+	lastSuccessAt   time.Time
+	lastFailureAt   time.Time
+	lastError       string
+	platformErrors  map[string]int64
+}
+
+func (s *relayerStats) started() {
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *relayerStats) broadcast() {
+	s.mu.Lock()
+	s.lastBroadcastAt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *relayerStats) success() {
+	s.mu.Lock()
+	s.lastSuccessAt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *relayerStats) failure(platform, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFailureAt = time.Now()
+	s.lastError = msg
+	if s.platformErrors == nil {
+		s.platformErrors = make(map[string]int64)
+	}
+	s.platformErrors[platform]++
+}
+
+// Status reports queue depth from the database plus in-process counters.
+func (r *SwapRelayer) Status(ctx context.Context) (*Status, error) {
+	queue, err := r.opts.Store.CountActiveSwapOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{
+		Running:        r.started && !r.isClosing(),
+		Listening:      r.stats.listening.Load(),
+		Workers:        r.opts.Workers,
+		InFlight:       r.stats.inFlight.Load(),
+		Queue:          queue,
+		PlatformErrors: make(map[string]int64),
+	}
+	r.stats.mu.Lock()
+	st.StartedAt = timePtr(r.stats.startedAt)
+	st.LastBroadcastAt = timePtr(r.stats.lastBroadcastAt)
+	st.LastSuccessAt = timePtr(r.stats.lastSuccessAt)
+	st.LastFailureAt = timePtr(r.stats.lastFailureAt)
+	st.LastError = r.stats.lastError
+	for platform, n := range r.stats.platformErrors {
+		st.PlatformErrors[platform] = n
+	}
+	lastActivity := r.stats.startedAt
+	for _, t := range []time.Time{r.stats.lastBroadcastAt, r.stats.lastFailureAt} {
+		if t.After(lastActivity) {
+			lastActivity = t
+		}
+	}
+	r.stats.mu.Unlock()
+	if queue["queued"] > 0 && st.InFlight == 0 {
+		st.Stalled = !st.Running || time.Since(lastActivity) > stallAfter
+	}
+	return st, nil
+}
+
+func (r *SwapRelayer) isClosing() bool {
+	select {
+	case <-r.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
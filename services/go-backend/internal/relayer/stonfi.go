@@ -0,0 +1,384 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+// Default STON.fi mainnet contracts, see https://docs.ston.fi/docs/developer-section.
+const (
+	stonfiV1Router = "EQB3ncyBUTjZUA5EnFKR5_EnOMI9V1tTEAAPaiU71gc4TiUt"
+	stonfiV1PTON   = "EQCM3B12QK1e4yZSf8GtBRT0aLMNyEsBc_DhVfRRtOEffLez"
+	stonfiV2Router = "EQCS4UEa5UaJLzOyyKieqQOQ2P9M-7kXpkO5HnP3Bv250cN3"
+	stonfiV2PTON   = "EQBnGWMCf3-FZZq1W4IWcWiGAc3PHuZ0_H-7sad2oY00o83S"
+)
+
+const (
+	stonfiOpSwapV1         = 0x25938561
+	stonfiOpSwapV2         = 0x6664de2a
+	stonfiOpPtonTransferV2 = 0x01f3835d
+	stonfiSwapDeadline     = 15 * time.Minute
+)
+
+var (
+	stonfiV1BuyForwardGas  = big.NewInt(215_000_000) // 0.215 TON
+	stonfiV1SellGas        = big.NewInt(170_000_000) // 0.17 TON
+	stonfiV1SellForwardGas = big.NewInt(125_000_000) // 0.125 TON
+	stonfiV2BuyForwardGas  = big.NewInt(300_000_000) // 0.3 TON
+	stonfiV2SellGas        = big.NewInt(300_000_000) // 0.3 TON
+	stonfiV2SellForwardGas = big.NewInt(240_000_000) // 0.24 TON
+)
+
+// StonfiOptions overrides the STON.fi router deployments. Empty fields keep
+// the mainnet defaults.
+type StonfiOptions struct {
+	V1Router string
+	V1PTON   string
+	V2Router string
+	V2PTON   string
+}
+
+// stonfiRouter is one STON.fi router deployment and its proxy-TON master.
+type stonfiRouter struct {
+	v2     bool
+	router *address.Address
+	pton   *address.Address
+}
+
+// stonfiAdapter routes swaps through whichever STON.fi router holds the
+// token's pool; v1 and v2 pools live side by side.
+type stonfiAdapter struct {
+	chain   ChainReader
+	routers []*stonfiRouter
+
+	mu            sync.Mutex
+	routerWallets map[string]*address.Address
+	// pools maps a quoted pool to the router it belongs to.
+	pools map[string]*stonfiRouter
+}
+
+// NewStonfiAdapter returns the STON.fi adapter for both router versions.
+func NewStonfiAdapter(chain ChainReader, opts StonfiOptions) (DEXAdapter, error) {
+	v1, err := newStonfiRouter(false, opts.V1Router, opts.V1PTON)
+	if err != nil {
+		return nil, err
+	}
+	v2, err := newStonfiRouter(true, opts.V2Router, opts.V2PTON)
+	if err != nil {
+		return nil, err
+	}
+	return &stonfiAdapter{
+		chain:         chain,
+		routers:       []*stonfiRouter{v1, v2},
+		routerWallets: make(map[string]*address.Address),
+		pools:         make(map[string]*stonfiRouter),
+	}, nil
+}
+
+// newStonfiRouter parses a router deployment, falling back to the mainnet
+// contracts of its version.
+func newStonfiRouter(v2 bool, router, pton string) (*stonfiRouter, error) {
+	version, defaultRouter, defaultPTON := "v1", stonfiV1Router, stonfiV1PTON
+	if v2 {
+		version, defaultRouter, defaultPTON = "v2", stonfiV2Router, stonfiV2PTON
+	}
+	if router = strings.TrimSpace(router); router == "" {
+		router = defaultRouter
+	}
+	if pton = strings.TrimSpace(pton); pton == "" {
+		pton = defaultPTON
+	}
+	routerAddr, err := address.ParseAddr(router)
+	if err != nil {
+		return nil, fmt.Errorf("stonfi %s router address: %w", version, err)
+	}
+	ptonAddr, err := address.ParseAddr(pton)
+	if err != nil {
+		return nil, fmt.Errorf("stonfi %s pton address: %w", version, err)
+	}
+	return &stonfiRouter{v2: v2, router: routerAddr, pton: ptonAddr}, nil
+}
+
+// routerWallet returns rt's jetton wallet for master (cached).
+func (s *stonfiAdapter) routerWallet(ctx context.Context, rt *stonfiRouter, master *address.Address) (*address.Address, error) {
+	key := rt.router.String() + "/" + master.String()
+	s.mu.Lock()
+	cached := s.routerWallets[key]
+	s.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	addr, err := s.chain.GetJettonWalletAddress(ctx, master, rt.router)
+	if err != nil {
+		return nil, fmt.Errorf("stonfi router wallet: %w", err)
+	}
+	s.mu.Lock()
+	s.routerWallets[key] = addr
+	s.mu.Unlock()
+	return addr, nil
+}
+
+// estimateSwapOut queries rt's TON/token pool for the expected output of
+// offering amountIn of TON (offerIsTon) or of the token. The router derives
+// the pool address whether or not it is deployed, so a missing pool shows
+// up as a failing get_expected_outputs.
+func (s *stonfiAdapter) estimateSwapOut(ctx context.Context, rt *stonfiRouter, token *address.Address, offerIsTon bool, amountIn *big.Int) (*address.Address, *big.Int, error) {
+	ptonWallet, err := s.routerWallet(ctx, rt, rt.pton)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokenWallet, err := s.routerWallet(ctx, rt, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := s.chain.RunGetMethod(ctx, rt.router.String(), "get_pool_address", ton.AddressArg(ptonWallet), ton.AddressArg(tokenWallet))
+	if err != nil {
+		return nil, nil, fmt.Errorf("stonfi pool: %w", err)
+	}
+	pool, err := res.Address(0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stonfi pool: %w", err)
+	}
+	offerWallet := tokenWallet
+	if offerIsTon {
+		offerWallet = ptonWallet
+	}
+	out, err := s.chain.RunGetMethod(ctx, pool.String(), "get_expected_outputs", ton.NumArg(amountIn), ton.AddressArg(offerWallet))
+	if err != nil {
+		if errors.Is(err, ton.ErrGetMethodFailed) {
+			return nil, nil, errPoolNotFound
+		}
+		return nil, nil, err
+	}
+	amountOut, err := out.Int(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pool, amountOut, nil
+}
+
+// Name implements DEXAdapter.
+func (s *stonfiAdapter) Name() string {
+	return "stonfi"
+}
+
+// Quote implements DEXAdapter. It asks every router for the token's pool and
+// takes the best output among those that have one.
+func (s *stonfiAdapter) Quote(ctx context.Context, req SwapRequest) (*Quote, error) {
+	buy := req.Direction != "sell"
+	var best *Quote
+	var bestRouter *stonfiRouter
+	for _, rt := range s.routers {
+		pool, out, err := s.estimateSwapOut(ctx, rt, req.Token, buy, req.AmountIn)
+		if errors.Is(err, errPoolNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if best != nil && out.Cmp(best.AmountOut) <= 0 {
+			continue
+		}
+		tonRequired := new(big.Int).Set(rt.sellGas())
+		if buy {
+			tonRequired = new(big.Int).Add(req.AmountIn, rt.buyGas())
+		}
+		best = &Quote{AmountIn: req.AmountIn, AmountOut: out, TonRequired: tonRequired, Pool: pool}
+		bestRouter = rt
+	}
+	if best == nil {
+		return nil, errPoolNotFound
+	}
+	s.mu.Lock()
+	s.pools[best.Pool.String()] = bestRouter
+	s.mu.Unlock()
+	return best, nil
+}
+
+// BuildSwap implements DEXAdapter. The swap goes through the router whose
+// pool was quoted.
+func (s *stonfiAdapter) BuildSwap(ctx context.Context, req SwapRequest, quote *Quote) ([]*wallet.Message, error) {
+	if quote == nil || quote.Pool == nil {
+		var err error
+		if quote, err = s.Quote(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	rt := s.pools[quote.Pool.String()]
+	s.mu.Unlock()
+	if rt == nil {
+		return nil, errPoolNotFound
+	}
+	minOut := req.MinOut
+	if minOut == nil {
+		minOut = big.NewInt(0)
+	}
+	var msg *wallet.Message
+	var err error
+	if req.Direction == "sell" {
+		msg, err = s.buildSell(ctx, rt, req.QueryID, req.Owner, req.JettonWallet, req.AmountIn, minOut)
+	} else {
+		msg, err = s.buildBuy(ctx, rt, req.QueryID, req.Owner, req.Token, req.AmountIn, minOut)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*wallet.Message{msg}, nil
+}
+
+// ParseResult implements DEXAdapter. The router pays TON out through its
+// proxy-TON wallet, which delivers it with a transfer_notification (v1) or a
+// ton_transfer (v2) echoing the swap's query id: the proceeds of a sell, or
+// the input of a buy the pool refunded. The message carries the TON itself,
+// which tells it apart from notifications of other jetton wallets.
+func (s *stonfiAdapter) ParseResult(msg *tlb.InternalMessage) (*SwapResult, error) {
+	op, queryID, body, ok := readOp(msg)
+	if !ok || (op != opJettonTransferNotification && op != stonfiOpPtonTransferV2) {
+		return nil, ErrUnrecognizedResult
+	}
+	amount, err := body.LoadBigCoins()
+	if err != nil || amount.Sign() <= 0 || msg.Amount.Nano().Cmp(amount) < 0 {
+		return nil, ErrUnrecognizedResult
+	}
+	return &SwapResult{QueryID: queryID, AmountOut: amount}, nil
+}
+
+func (rt *stonfiRouter) buyGas() *big.Int {
+	if rt.v2 {
+		return stonfiV2BuyForwardGas
+	}
+	return stonfiV1BuyForwardGas
+}
+
+func (rt *stonfiRouter) sellGas() *big.Int {
+	if rt.v2 {
+		return stonfiV2SellGas
+	}
+	return stonfiV1SellGas
+}
+
+// swapPayload encodes the router swap instruction; askWallet is the router's
+// wallet for the asset being bought.
+func (rt *stonfiRouter) swapPayload(askWallet, recipient *address.Address, minOut *big.Int) *cell.Cell {
+	if !rt.v2 {
+		return cell.BeginCell().
+			MustStoreUInt(stonfiOpSwapV1, 32).
+			MustStoreAddr(askWallet).
+			MustStoreBigCoins(minOut).
+			MustStoreAddr(recipient).
+			MustStoreBoolBit(false). // no referral
+			EndCell()
+	}
+	params := cell.BeginCell().
+		MustStoreBigCoins(minOut).
+		MustStoreAddr(recipient).
+		MustStoreCoins(0). // fwd_gas
+		MustStoreMaybeRef(nil).
+		MustStoreCoins(0). // refund_fwd_gas
+		MustStoreMaybeRef(nil).
+		MustStoreUInt(10, 16). // ref_fee, ignored without ref_address
+		MustStoreAddr(nil).
+		EndCell()
+	return cell.BeginCell().
+		MustStoreUInt(stonfiOpSwapV2, 32).
+		MustStoreAddr(askWallet).
+		MustStoreAddr(recipient). // refund_address
+		MustStoreAddr(recipient). // excesses_address
+		MustStoreUInt(uint64(time.Now().Add(stonfiSwapDeadline).Unix()), 64).
+		MustStoreRef(params).
+		EndCell()
+}
+
+// buildBuy sends TON through the router's proxy-TON wallet.
+func (s *stonfiAdapter) buildBuy(ctx context.Context, rt *stonfiRouter, queryID uint64, owner, token *address.Address, amountIn, minOut *big.Int) (*wallet.Message, error) {
+	ptonWallet, err := s.routerWallet(ctx, rt, rt.pton)
+	if err != nil {
+		return nil, err
+	}
+	askWallet, err := s.routerWallet(ctx, rt, token)
+	if err != nil {
+		return nil, err
+	}
+	payload := rt.swapPayload(askWallet, owner, minOut)
+	forwardGas := rt.buyGas()
+	var body *cell.Cell
+	if rt.v2 {
+		body = cell.BeginCell().
+			MustStoreUInt(stonfiOpPtonTransferV2, 32).
+			MustStoreUInt(queryID, 64).
+			MustStoreBigCoins(amountIn).
+			MustStoreAddr(owner).
+			MustStoreBoolBit(true).
+			MustStoreRef(payload).
+			EndCell()
+	} else {
+		body, err = ton.BuildJettonTransferBody(ton.JettonTransferParams{
+			QueryID:         queryID,
+			Amount:          amountIn,
+			Destination:     rt.router,
+			ResponseAddress: owner,
+			ForwardTon:      forwardGas,
+			ForwardPayload:  payload,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	value := new(big.Int).Add(amountIn, forwardGas)
+	return &wallet.Message{
+		Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+		InternalMessage: &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      true,
+			DstAddr:     ptonWallet,
+			Amount:      tlb.FromNanoTON(value),
+			Body:        body,
+		},
+	}, nil
+}
+
+// buildSell transfers jettons from the owner's jetton wallet to the router.
+func (s *stonfiAdapter) buildSell(ctx context.Context, rt *stonfiRouter, queryID uint64, owner, jettonWallet *address.Address, amountIn, minOut *big.Int) (*wallet.Message, error) {
+	askWallet, err := s.routerWallet(ctx, rt, rt.pton)
+	if err != nil {
+		return nil, err
+	}
+	forwardGas := stonfiV1SellForwardGas
+	if rt.v2 {
+		forwardGas = stonfiV2SellForwardGas
+	}
+	body, err := ton.BuildJettonTransferBody(ton.JettonTransferParams{
+		QueryID:         queryID,
+		Amount:          amountIn,
+		Destination:     rt.router,
+		ResponseAddress: owner,
+		ForwardTon:      forwardGas,
+		ForwardPayload:  rt.swapPayload(askWallet, owner, minOut),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet.Message{
+		Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+		InternalMessage: &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      true,
+			DstAddr:     jettonWallet,
+			Amount:      tlb.FromNanoTON(rt.sellGas()),
+			Body:        body,
+		},
+	}, nil
+}
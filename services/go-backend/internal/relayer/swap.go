@@ -0,0 +1,300 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/crypto"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
+)
+
+var (
+	errWalletNotFound      = errors.New("wallet_not_found")
+	errInvalidTokenAddress = errors.New("invalid_token_address")
+	errInvalidTonAmount    = errors.New("invalid_ton_amount")
+	errInvalidPercent      = errors.New("invalid_percent")
+	errMissingSellPercent  = errors.New("missing_sell_percent")
+	errInsufficientBalance = errors.New("insufficient_ton_balance")
+	errInsufficientFees    = errors.New("insufficient_ton_for_fees")
+	errJettonBalanceZero   = errors.New("jetton_balance_zero")
+	errZeroAmountOut       = errors.New("zero_amount_out")
+	errAmountTooSmall      = errors.New("amount_too_small")
+	errUnsupportedPlatform = errors.New("unsupported_platform")
+	errPriceBeyondLimit    = errors.New("price_exceeds_limit")
+	errDecryptFailed       = errors.New("decrypt_failed")
+	errInvalidSlippage     = errors.New("invalid_slippage")
+	errOrderReleased       = errors.New("order_released")
+)
+
+// defaultSlippage is the slippage percent used when neither the order nor
+// the relayer options specify one.
+const defaultSlippage = 5.0
+
+// orderRejections are problems with the order itself; re-quoting it later
+// cannot succeed.
+var orderRejections = []error{
+	errWalletNotFound,
+	errInvalidTokenAddress,
+	errInvalidTonAmount,
+	errInvalidPercent,
+	errMissingSellPercent,
+	errJettonBalanceZero,
+	errAmountTooSmall,
+	errUnsupportedPlatform,
+	errInvalidLimitPrice,
+	errInvalidSlippage,
+}
+
+func isOrderRejection(err error) bool {
+	for _, target := range orderRejections {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// swapLeg is one order's part of a batched external message.
+type swapLeg struct {
+	order       *database.SwapOrder
+	msgs        []*wallet.Message
+	amountIn    *big.Int
+	tonRequired *big.Int
+}
+
+// buildLeg quotes the order and builds its swap messages. available is the
+// wallet's TON balance not yet committed to earlier orders of the batch.
+func (r *SwapRelayer) buildLeg(ctx context.Context, order *database.SwapOrder, owner *address.Address, available *big.Int) (*swapLeg, error) {
+	adapter, err := r.orderAdapter(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.swapRequest(ctx, order, owner)
+	if err != nil {
+		return nil, err
+	}
+	if order.LimitPrice != nil {
+		if req.MinOut, err = r.limitMinOut(ctx, order, req); err != nil {
+			return nil, err
+		}
+	}
+	quote, err := adapter.Quote(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if quote.AmountOut == nil || quote.AmountOut.Sign() <= 0 {
+		return nil, errZeroAmountOut
+	}
+	if quote.AmountOut.Cmp(req.MinOut) < 0 {
+		return nil, errPriceBeyondLimit
+	}
+	// The message carries the stricter of the limit bound and the quote
+	// minus slippage, so the DEX refunds instead of filling a moved pool.
+	quoted, err := r.slippageMinOut(order, quote.AmountOut)
+	if err != nil {
+		return nil, err
+	}
+	if quoted.Cmp(req.MinOut) > 0 {
+		req.MinOut = quoted
+	}
+	if available.Cmp(quote.TonRequired) < 0 {
+		if req.Direction == "sell" {
+			return nil, errInsufficientFees
+		}
+		return nil, errInsufficientBalance
+	}
+	msgs, err := adapter.BuildSwap(ctx, req, quote)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, errors.New("adapter built no messages")
+	}
+	return &swapLeg{order: order, msgs: msgs, amountIn: req.AmountIn, tonRequired: quote.TonRequired}, nil
+}
+
+// orderAdapter returns the adapter for the order's platform. Launchpad tokens
+// that have migrated to a DEX are rerouted there, and the order is updated so
+// fills are read with the right venue.
+func (r *SwapRelayer) orderAdapter(ctx context.Context, order *database.SwapOrder) (DEXAdapter, error) {
+	token, err := address.ParseAddr(strings.TrimSpace(order.TokenAddress))
+	if err != nil {
+		return nil, errInvalidTokenAddress
+	}
+	adapter, err := r.venue(ctx, order.Platform, token)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(adapter.Name(), order.Platform) {
+		return adapter, nil
+	}
+	if err := r.opts.Store.SetSwapOrderPlatform(ctx, order.ID, adapter.Name()); err != nil {
+		return nil, err
+	}
+	r.log("swap order %d: %s token migrated, routing to %s", order.ID, order.Platform, adapter.Name())
+	order.Platform = adapter.Name()
+	return adapter, nil
+}
+
+// venue returns the adapter token currently trades on for platform,
+// following a launchpad migration to its DEX.
+func (r *SwapRelayer) venue(ctx context.Context, platform string, token *address.Address) (DEXAdapter, error) {
+	adapter, ok := r.adapters.Get(platform)
+	if !ok {
+		return nil, errUnsupportedPlatform
+	}
+	migrator, ok := adapter.(Migrator)
+	if !ok {
+		return adapter, nil
+	}
+	target, err := migrator.MigratedTo(ctx, token)
+	if err != nil || target == "" {
+		return adapter, err
+	}
+	dex, ok := r.adapters.Get(target)
+	if !ok {
+		return nil, errUnsupportedPlatform
+	}
+	return dex, nil
+}
+
+func (r *SwapRelayer) loadWallet(ctx context.Context, order *database.SwapOrder) (*wallet.Wallet, error) {
+	row, err := r.opts.Store.GetWalletSecretByID(ctx, order.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil || row.UserID != order.UserID {
+		return nil, errWalletNotFound
+	}
+	mnemonic, err := crypto.DecryptMnemonic(r.opts.MasterKey, row.EncryptedMnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecryptFailed, err)
+	}
+	version, err := ton.ParseWalletVersion(row.Version)
+	if err != nil {
+		return nil, err
+	}
+	contract, err := r.opts.TonClient.OpenWallet(mnemonic, version)
+	if err != nil {
+		return nil, err
+	}
+	if stored, err := address.ParseAddr(row.Address); err == nil && !stored.Equals(contract.WalletAddress()) {
+		r.log("wallet %d address mismatch: stored %s derived %s", row.ID, row.Address, contract.WalletAddress().String())
+	}
+	return contract, nil
+}
+
+// orderQueryID is the query id of an order's swap request. Venues echo it in
+// their payouts, which is how settlement ties them to the order.
+func orderQueryID(order *database.SwapOrder) uint64 {
+	return uint64(order.ID)
+}
+
+// swapRequest resolves the order into concrete amounts: the TON to spend for
+// buys, or sell_percent of the on-chain jetton balance for sells.
+func (r *SwapRelayer) swapRequest(ctx context.Context, order *database.SwapOrder, owner *address.Address) (SwapRequest, error) {
+	token, err := address.ParseAddr(strings.TrimSpace(order.TokenAddress))
+	if err != nil {
+		return SwapRequest{}, errInvalidTokenAddress
+	}
+	req := SwapRequest{
+		QueryID:   orderQueryID(order),
+		Direction: order.Direction,
+		Owner:     owner,
+		Token:     token,
+		MinOut:    big.NewInt(0),
+	}
+	if order.Direction != "sell" {
+		if order.TonAmount == nil {
+			return SwapRequest{}, errInvalidTonAmount
+		}
+		amount, err := tlb.FromTON(strings.TrimSpace(*order.TonAmount))
+		if err != nil || amount.Nano().Sign() <= 0 {
+			return SwapRequest{}, errInvalidTonAmount
+		}
+		req.Direction = "buy"
+		req.AmountIn = amount.Nano()
+		return req, nil
+	}
+	if order.SellPercent == nil || strings.TrimSpace(*order.SellPercent) == "" {
+		return SwapRequest{}, errMissingSellPercent
+	}
+	jettonWallet, err := r.opts.TonClient.GetJettonWalletAddress(ctx, token, req.Owner)
+	if err != nil {
+		return SwapRequest{}, fmt.Errorf("jetton wallet: %w", err)
+	}
+	balance, err := r.opts.TonClient.GetJettonWalletBalance(ctx, jettonWallet)
+	if err != nil {
+		return SwapRequest{}, fmt.Errorf("jetton balance: %w", err)
+	}
+	if balance.Sign() <= 0 {
+		return SwapRequest{}, errJettonBalanceZero
+	}
+	amountIn, err := takePercent(balance, *order.SellPercent)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	if amountIn.Sign() <= 0 {
+		return SwapRequest{}, errAmountTooSmall
+	}
+	req.JettonWallet = jettonWallet
+	req.AmountIn = amountIn
+	return req, nil
+}
+
+// slippageMinOut returns the quoted output reduced by the order's slippage
+// percent (or the relayer default).
+func (r *SwapRelayer) slippageMinOut(order *database.SwapOrder, amountOut *big.Int) (*big.Int, error) {
+	slippage := new(big.Rat).SetFloat64(r.opts.DefaultSlippage)
+	if order.Slippage != nil {
+		var ok bool
+		if slippage, ok = new(big.Rat).SetString(strings.TrimSpace(*order.Slippage)); !ok {
+			return nil, errInvalidSlippage
+		}
+	}
+	hundred := big.NewRat(100, 1)
+	if slippage == nil || slippage.Sign() <= 0 || slippage.Cmp(hundred) > 0 {
+		return nil, errInvalidSlippage
+	}
+	keep := new(big.Rat).Sub(hundred, slippage)
+	out := new(big.Rat).Mul(new(big.Rat).SetInt(amountOut), keep)
+	out.Quo(out, hundred)
+	return new(big.Int).Quo(out.Num(), out.Denom()), nil
+}
+
+// tonBalance returns the wallet's current balance in nanoTON.
+func (r *SwapRelayer) tonBalance(ctx context.Context, owner *address.Address) (*big.Int, error) {
+	bal, err := r.opts.TonClient.GetAccountBalance(ctx, owner.String())
+	if err != nil {
+		return nil, fmt.Errorf("wallet balance: %w", err)
+	}
+	nano, ok := new(big.Int).SetString(strings.TrimSpace(bal.Nano), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %q", bal.Nano)
+	}
+	return nano, nil
+}
+
+// takePercent returns percent% of balance, rounding down but never to zero
+// for a non-empty balance.
+func takePercent(balance *big.Int, percent string) (*big.Int, error) {
+	p, ok := new(big.Rat).SetString(strings.TrimSpace(percent))
+	if !ok || p.Sign() <= 0 || p.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, errInvalidPercent
+	}
+	share := new(big.Rat).Mul(new(big.Rat).SetInt(balance), p)
+	share.Quo(share, big.NewRat(100, 1))
+	amount := new(big.Int).Quo(share.Num(), share.Denom())
+	if amount.Sign() <= 0 && balance.Sign() > 0 {
+		return big.NewInt(1), nil
+	}
+	return amount, nil
+}
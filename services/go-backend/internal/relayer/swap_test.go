@@ -0,0 +1,81 @@
+package relayer
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+func TestTakePercent(t *testing.T) {
+	tests := []struct {
+		balance int64
+		percent string
+		want    int64
+	}{
+		{1_000_000, "100", 1_000_000},
+		{1_000_000, "50", 500_000},
+		{1_000_000, " 25 ", 250_000},
+		{1_000_000, "33.33", 333_300},
+		{999, "50", 499},         // rounds down
+		{3, "10", 1},             // never rounds a non-empty balance to zero
+		{0, "50", 0},             // nothing to take
+		{1_000_000, "0.0001", 1}, // tiny shares still move one unit
+	}
+	for _, tt := range tests {
+		got, err := takePercent(big.NewInt(tt.balance), tt.percent)
+		if err != nil {
+			t.Errorf("takePercent(%d, %q): %v", tt.balance, tt.percent, err)
+			continue
+		}
+		if got.Int64() != tt.want {
+			t.Errorf("takePercent(%d, %q) = %s, want %d", tt.balance, tt.percent, got, tt.want)
+		}
+	}
+	for _, percent := range []string{"0", "-5", "100.01", "abc", ""} {
+		if _, err := takePercent(big.NewInt(100), percent); !errors.Is(err, errInvalidPercent) {
+			t.Errorf("takePercent(100, %q) error = %v, want errInvalidPercent", percent, err)
+		}
+	}
+}
+
+func TestSlippageMinOut(t *testing.T) {
+	r := New(Options{DefaultSlippage: 2, Adapters: NewRegistry()})
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		slippage *string
+		out      int64
+		want     int64
+	}{
+		{"relayer default", nil, 1_000_000, 980_000},
+		{"order slippage", str("10"), 1_000_000, 900_000},
+		{"fractional slippage", str("0.5"), 1_000_000, 995_000},
+		{"rounds down", str("1"), 999, 989},
+		{"full slippage", str("100"), 1_000_000, 0},
+	}
+	for _, tt := range tests {
+		got, err := r.slippageMinOut(&database.SwapOrder{Slippage: tt.slippage}, big.NewInt(tt.out))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Int64() != tt.want {
+			t.Errorf("%s: slippageMinOut(%d) = %s, want %d", tt.name, tt.out, got, tt.want)
+		}
+	}
+	for _, slippage := range []string{"0", "-1", "101", "abc"} {
+		if _, err := r.slippageMinOut(&database.SwapOrder{Slippage: str(slippage)}, big.NewInt(100)); !errors.Is(err, errInvalidSlippage) {
+			t.Errorf("slippage %q: error = %v, want errInvalidSlippage", slippage, err)
+		}
+	}
+}
+
+func TestNewDefaultSlippage(t *testing.T) {
+	for _, opt := range []float64{0, -1, 150} {
+		if got := New(Options{DefaultSlippage: opt, Adapters: NewRegistry()}).opts.DefaultSlippage; got != defaultSlippage {
+			t.Errorf("DefaultSlippage %v: got %v, want %v", opt, got, defaultSlippage)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+// trailingQuoteSizes are tried in turn when the stop has no position to size
+// the quote by: one token first to keep price impact low, then larger
+// amounts for tokens worth less than a nanoTON each.
+var trailingQuoteSizes = []string{"1", "1000", "1000000", "1000000000"}
+
+// checkTrailingStops re-prices active trailing stops, raising their
+// high-water marks and queuing a sell once the drawdown from the peak
+// reaches trail_percent.
+func (r *SwapRelayer) checkTrailingStops(ctx context.Context) error {
+	stops, err := r.opts.Store.ListActiveTrailingStops(ctx, limitBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range stops {
+		if err := r.checkTrailingStop(ctx, &stops[i]); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			r.log("trailing stop %d: %v", stops[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *SwapRelayer) checkTrailingStop(ctx context.Context, stop *database.TrailingStop) error {
+	trail, ok := new(big.Rat).SetString(strings.TrimSpace(stop.TrailPercent))
+	if !ok || trail.Sign() <= 0 || trail.Cmp(big.NewRat(100, 1)) >= 0 {
+		return errInvalidTrigger
+	}
+	// Quote the position's size and divide: a single token of a cheap
+	// memecoin is worth less than a nanoTON and would always quote zero.
+	sizes := trailingQuoteSizes
+	amount, err := r.opts.Store.GetPositionAmount(ctx, stop.UserID, stop.WalletID, stop.TokenAddress)
+	if err != nil {
+		return err
+	}
+	if amount != nil {
+		if held, ok := new(big.Rat).SetString(strings.TrimSpace(*amount)); ok && held.Sign() > 0 {
+			sizes = []string{strings.TrimSpace(*amount)}
+		}
+	}
+	var price *big.Rat
+	for _, size := range sizes {
+		value, err := r.positionValue(ctx, stop.Platform, stop.TokenAddress, size)
+		if err != nil {
+			return err
+		}
+		if value != nil && value.Sign() > 0 {
+			tokens, _ := new(big.Rat).SetString(size)
+			price = value.Quo(value, tokens)
+			break
+		}
+	}
+	if price == nil {
+		return nil
+	}
+	priceText := formatPrice(price)
+	if stop.HighWaterPrice != nil {
+		peak, ok := new(big.Rat).SetString(strings.TrimSpace(*stop.HighWaterPrice))
+		if ok && peak.Sign() > 0 && price.Cmp(peak) < 0 {
+			keep := new(big.Rat).Sub(big.NewRat(1, 1), new(big.Rat).Quo(trail, big.NewRat(100, 1)))
+			if price.Cmp(new(big.Rat).Mul(peak, keep)) <= 0 {
+				order, err := r.opts.Store.FireTrailingStop(ctx, stop.ID, priceText)
+				if err != nil {
+					return err
+				}
+				if order != nil {
+					r.log("trailing stop %d fired at %s TON (peak %s): sell order %d queued", stop.ID, priceText, *stop.HighWaterPrice, order.ID)
+				}
+				return nil
+			}
+		}
+	}
+	return r.opts.Store.RecordTrailingPrice(ctx, stop.ID, priceText)
+}
+
+// formatPrice renders a TON-per-token price with enough fractional digits
+// for tokens worth a tiny fraction of a nanoTON.
+func formatPrice(price *big.Rat) string {
+	text := price.FloatString(30)
+	text = strings.TrimRight(text, "0")
+	return strings.TrimSuffix(text, ".")
+}
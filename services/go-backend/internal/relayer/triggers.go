@@ -0,0 +1,108 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+)
+
+var errInvalidTrigger = errors.New("invalid_trigger")
+
+// checkTriggers quotes positions that carry take-profit / stop-loss rules
+// and queues a sell for every rule whose threshold has been crossed.
+func (r *SwapRelayer) checkTriggers(ctx context.Context) error {
+	triggers, err := r.opts.Store.ListArmedTriggers(ctx, limitBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range triggers {
+		trig := &triggers[i]
+		hit, err := r.triggerHit(ctx, trig)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			r.log("trigger %d: %v", trig.ID, err)
+			continue
+		}
+		if !hit {
+			continue
+		}
+		order, err := r.opts.Store.FirePositionTrigger(ctx, trig.ID)
+		if err != nil {
+			return err
+		}
+		if order != nil {
+			r.log("trigger %d (%s) fired on position %d: sell order %d queued", trig.ID, trig.Kind, trig.PositionID, order.ID)
+		}
+	}
+	return nil
+}
+
+// triggerHit compares the position's current TON value with the rule.
+func (r *SwapRelayer) triggerHit(ctx context.Context, trig *database.ArmedTrigger) (bool, error) {
+	threshold, ok := new(big.Rat).SetString(strings.TrimSpace(trig.Threshold))
+	if !ok || threshold.Sign() <= 0 {
+		return false, errInvalidTrigger
+	}
+	value, err := r.positionValue(ctx, trig.Platform, trig.TokenAddress, trig.Amount)
+	if err != nil || value == nil {
+		return false, err
+	}
+	target := threshold
+	if trig.ThresholdType == "percent" {
+		invested, ok := new(big.Rat).SetString(strings.TrimSpace(trig.InvestedTon))
+		if !ok || invested.Sign() <= 0 {
+			// Nothing to measure the move against.
+			return false, nil
+		}
+		move := new(big.Rat).Quo(threshold, big.NewRat(100, 1))
+		if trig.Kind == "stop_loss" {
+			move.Neg(move)
+		}
+		target = new(big.Rat).Mul(invested, move.Add(move, big.NewRat(1, 1)))
+	}
+	if trig.Kind == "stop_loss" {
+		return value.Cmp(target) <= 0, nil
+	}
+	return value.Cmp(target) >= 0, nil
+}
+
+// positionValue quotes selling amount whole tokens on platform and returns
+// the proceeds in TON, or nil when the position is too small to quote.
+func (r *SwapRelayer) positionValue(ctx context.Context, platform, tokenAddress, amount string) (*big.Rat, error) {
+	token, err := address.ParseAddr(strings.TrimSpace(tokenAddress))
+	if err != nil {
+		return nil, errInvalidTokenAddress
+	}
+	tokens, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return nil, errInvalidTrigger
+	}
+	adapter, err := r.venue(ctx, platform, token)
+	if err != nil {
+		return nil, err
+	}
+	decimals, err := r.tokenDecimals(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	raw := tokens.Mul(tokens, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	amountIn := new(big.Int).Quo(raw.Num(), raw.Denom())
+	if amountIn.Sign() <= 0 {
+		return nil, nil
+	}
+	quote, err := adapter.Quote(ctx, SwapRequest{Direction: "sell", Token: token, AmountIn: amountIn, MinOut: big.NewInt(0)})
+	if err != nil {
+		return nil, err
+	}
+	if quote.AmountOut == nil {
+		return nil, errZeroAmountOut
+	}
+	return new(big.Rat).Quo(new(big.Rat).SetInt(quote.AmountOut), nanoPerTon), nil
+}
@@ -2,13 +2,17 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/xssnick/tonutils-go/address"
+
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/crypto"
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
@@ -22,6 +26,7 @@ func (s *Server) registerRoutes() {
 
 	e.GET("/health", s.handleHealth)
 	e.GET("/diag", s.handleDiag)
+	e.GET("/relayer/status", s.handleRelayerStatus)
 
 	e.GET("/wallets", s.handleListWallets)
 	e.GET("/wallets/:id", s.handleGetWallet)
@@ -30,10 +35,16 @@ func (s *Server) registerRoutes() {
 	e.GET("/wallets/:id/address", s.handleWalletAddressFormats)
 	e.GET("/wallets/:id/balance", s.handleWalletBalance)
 	e.GET("/wallets/:id/max_sendable", s.handleWalletMaxSendable)
+	e.GET("/wallets/:id/jettons", s.handleWalletJettons)
+	e.GET("/wallets/:id/transactions", s.handleWalletTransactions)
+	e.GET("/wallets/:id/transfers/:msg_hash", s.handleTransferStatus)
 	e.POST("/wallets/:id/seed", s.handleWalletSeed)
 	e.GET("/swap_orders", s.handleSwapOrders)
+	e.POST("/swap_orders/:id/requeue", s.handleRequeueSwapOrder)
+	e.POST("/swap_orders/:id/cancel", s.handleCancelSwapOrder)
 
 	e.POST("/transfer", s.handleTransfer)
+	e.POST("/transfer/jetton", s.handleJettonTransfer)
 
 	e.GET("/trading/profile", s.handleTradingProfile)
 	e.POST("/trading/profile", s.handleTradingProfileUpsert)
@@ -43,6 +54,20 @@ func (s *Server) registerRoutes() {
 
 	e.GET("/positions", s.handleListPositions)
 	e.POST("/positions/:id/hide", s.handleHidePosition)
+	e.POST("/positions/:id/triggers", s.handleCreatePositionTrigger)
+	e.GET("/position_triggers", s.handleListPositionTriggers)
+	e.POST("/position_triggers/:id/cancel", s.handleCancelPositionTrigger)
+
+	e.POST("/trailing_stops", s.handleCreateTrailingStop)
+	e.GET("/trailing_stops", s.handleListTrailingStops)
+	e.POST("/trailing_stops/:id/cancel", s.handleCancelTrailingStop)
+
+	e.POST("/dca_plans", s.handleCreateDCAPlan)
+	e.GET("/dca_plans", s.handleListDCAPlans)
+	e.GET("/dca_plans/:id", s.handleGetDCAPlan)
+	e.POST("/dca_plans/:id/pause", s.handleSetDCAPlanStatus("paused"))
+	e.POST("/dca_plans/:id/resume", s.handleSetDCAPlanStatus("active"))
+	e.POST("/dca_plans/:id/cancel", s.handleSetDCAPlanStatus("canceled"))
 }
 
 func (s *Server) handleSwapOrders(c echo.Context) error {
@@ -58,15 +83,97 @@ func (s *Server) handleSwapOrders(c echo.Context) error {
 	return c.JSON(http.StatusOK, rows)
 }
 
+// handleRequeueSwapOrder returns a user's dead or failed order to the relayer
+// queue; re-running a swap spends the owner's funds, so only the owner may.
+func (s *Server) handleRequeueSwapOrder(c echo.Context) error {
+	orderID, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	var payload struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	ctx := c.Request().Context()
+	row, err := s.opts.Store.RequeueSwapOrder(ctx, payload.UserID, orderID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "update_failed")
+	}
+	if row != nil {
+		return c.JSON(http.StatusOK, map[string]any{"order": row})
+	}
+	current, err := s.opts.Store.GetSwapOrder(ctx, orderID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if current == nil || current.UserID != payload.UserID {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	return echo.NewHTTPError(http.StatusConflict, "not_requeueable")
+}
+
+// handleCancelSwapOrder cancels a user's order that has not been signed yet.
+func (s *Server) handleCancelSwapOrder(c echo.Context) error {
+	orderID, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	var payload struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	ctx := c.Request().Context()
+	row, err := s.opts.Store.CancelSwapOrder(ctx, payload.UserID, orderID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "update_failed")
+	}
+	if row != nil {
+		return c.JSON(http.StatusOK, map[string]any{"order": row})
+	}
+	current, err := s.opts.Store.GetSwapOrder(ctx, orderID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if current == nil || current.UserID != payload.UserID {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	return echo.NewHTTPError(http.StatusConflict, "not_cancelable")
+}
+
 func (s *Server) handleHealth(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]any{"ok": true})
 }
 
+// handleRelayerStatus reports relayer health; it answers 503 when the relayer
+// is stalled so plain HTTP probes can alert on it.
+func (s *Server) handleRelayerStatus(c echo.Context) error {
+	if s.opts.Relayer == nil {
+		return c.JSON(http.StatusOK, map[string]any{"enabled": false})
+	}
+	status, err := s.opts.Relayer.Status(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	code := http.StatusOK
+	if status.Stalled {
+		code = http.StatusServiceUnavailable
+	}
+	return c.JSON(code, map[string]any{"enabled": true, "relayer": status})
+}
+
 func (s *Server) handleDiag(c echo.Context) error {
-	return c.JSON(http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"endpoint":  s.opts.Config.TonEndpoint,
 		"apiKeySet": s.opts.Config.TonAPIKey != "",
-	})
+	}
+	if s.opts.TonClient != nil {
+		resp["endpoints"] = s.opts.TonClient.Endpoints()
+	}
+	return c.JSON(http.StatusOK, resp)
 }
 
 func (s *Server) handleListWallets(c echo.Context) error {
@@ -121,11 +228,20 @@ func (s *Server) handleCreateWallet(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "server_misconfiguration")
 	}
 	var payload struct {
-		UserID int64 `json:"user_id"`
+		UserID  int64  `json:"user_id"`
+		Version string `json:"version"`
 	}
 	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
 		return echo.NewHTTPError(http.StatusBadRequest, "user_id required")
 	}
+	requested := payload.Version
+	if strings.TrimSpace(requested) == "" {
+		requested = s.opts.Config.WalletVersion
+	}
+	version, err := ton.ParseWalletVersion(requested)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported_wallet_version")
+	}
 	ctx := c.Request().Context()
 	count, err := s.opts.Store.CountWalletsByUser(ctx, payload.UserID)
 	if err != nil {
@@ -141,7 +257,7 @@ func (s *Server) handleCreateWallet(c echo.Context) error {
 	if s.opts.TonClient == nil {
 		return echo.NewHTTPError(http.StatusServiceUnavailable, "ton_client_unavailable")
 	}
-	address, err := s.opts.TonClient.DeriveWalletAddress(words)
+	address, err := s.opts.TonClient.DeriveWalletAddress(words, version)
 	if err != nil {
 		if errors.Is(err, ton.ErrNotImplemented) {
 			return echo.NewHTTPError(http.StatusNotImplemented, "derive_address_not_supported")
@@ -152,7 +268,7 @@ func (s *Server) handleCreateWallet(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "encrypt_failed")
 	}
-	row, err := s.opts.Store.InsertWallet(ctx, payload.UserID, address, enc)
+	row, err := s.opts.Store.InsertWallet(ctx, payload.UserID, address, enc, string(version))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "insert_failed")
 	}
@@ -209,6 +325,132 @@ func (s *Server) handleWalletBalance(c echo.Context) error {
 	})
 }
 
+// maxWalletJettons bounds how many jettons one listing reads on-chain.
+const maxWalletJettons = 50
+
+// handleWalletJettons lists the wallet's jetton holdings read straight from
+// the chain. Jettons come from the wallet's positions and orders plus any
+// masters passed in ?tokens=a,b; zero balances are hidden unless
+// ?include_zero=1.
+func (s *Server) handleWalletJettons(c echo.Context) error {
+	if s.opts.TonClient == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "ton_client_unavailable")
+	}
+	id, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	ctx := c.Request().Context()
+	row, err := s.opts.Store.GetWalletByID(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if row == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	owner, err := address.ParseAddr(row.Address)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid_wallet_address")
+	}
+	var requested []string
+	for _, token := range strings.Split(c.QueryParam("tokens"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			requested = append(requested, token)
+		}
+	}
+	known, err := s.opts.Store.ListWalletTokens(ctx, id, maxWalletJettons)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	var masters []*address.Address
+	seen := make(map[string]bool)
+	for i, token := range append(requested, known...) {
+		master, err := address.ParseAddr(token)
+		if err != nil {
+			if i < len(requested) {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid_token_address")
+			}
+			continue
+		}
+		key := master.StringRaw()
+		if seen[key] || len(masters) >= maxWalletJettons {
+			continue
+		}
+		seen[key] = true
+		masters = append(masters, master)
+	}
+	balances, err := s.opts.TonClient.GetJettonBalances(ctx, owner, masters)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("ton_error: %v", err))
+	}
+	includeZero := c.QueryParam("include_zero") == "1"
+	jettons := make([]ton.JettonBalance, 0, len(balances))
+	for _, bal := range balances {
+		if bal.Error == "" && !includeZero && strings.TrimSpace(bal.Balance) == "0" {
+			continue
+		}
+		jettons = append(jettons, bal)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"wallet_id": row.ID,
+		"address":   row.Address,
+		"jettons":   jettons,
+	})
+}
+
+// handleWalletTransactions pages through the wallet's on-chain history,
+// newest first. ?cursor= takes next_cursor from the previous page; ?limit=
+// defaults to ton.DefaultHistoryPage and is capped at ton.MaxHistoryPage.
+func (s *Server) handleWalletTransactions(c echo.Context) error {
+	if s.opts.TonClient == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "ton_client_unavailable")
+	}
+	id, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	limit := ton.DefaultHistoryPage
+	if v := strings.TrimSpace(c.QueryParam("limit")); v != "" {
+		n, err := parseInt64(v)
+		if err != nil || n <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid_limit")
+		}
+		limit = int(min(n, ton.MaxHistoryPage))
+	}
+	var cursor *ton.TxCursor
+	if v := strings.TrimSpace(c.QueryParam("cursor")); v != "" {
+		if cursor, err = ton.ParseTxCursor(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid_cursor")
+		}
+	}
+	ctx := c.Request().Context()
+	row, err := s.opts.Store.GetWalletByID(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if row == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	owner, err := address.ParseAddr(row.Address)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid_wallet_address")
+	}
+	txs, next, err := s.opts.TonClient.GetTransactions(ctx, owner, cursor, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("ton_error: %v", err))
+	}
+	var nextCursor any
+	if next != nil {
+		nextCursor = next.String()
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"wallet_id":    row.ID,
+		"address":      row.Address,
+		"transactions": txs,
+		"next_cursor":  nextCursor,
+	})
+}
+
 func (s *Server) handleDeleteWallet(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil || id <= 0 {
@@ -326,12 +568,14 @@ func (s *Server) handleTransfer(c echo.Context) error {
 	if payload.Comment != nil {
 		comment = *payload.Comment
 	}
-	if err := s.opts.TonClient.Transfer(ctx, ton.TransferRequest{
+	sent, err := s.opts.TonClient.Transfer(ctx, ton.TransferRequest{
 		Mnemonic:  mnemonic,
 		To:        payload.To,
 		AmountTon: payload.AmountTon,
 		Comment:   comment,
-	}); err != nil {
+		Version:   ton.WalletVersion(row.Version),
+	})
+	if err != nil {
 		if errors.Is(err, ton.ErrNotImplemented) {
 			return echo.NewHTTPError(http.StatusNotImplemented, "ton_transfer_not_ready")
 		}
@@ -343,7 +587,138 @@ func (s *Server) handleTransfer(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("ton_transfer_failed: %v", err))
 	}
-	return c.JSON(http.StatusOK, map[string]any{"ok": true})
+	return respondSent(c, sent)
+}
+
+func (s *Server) handleJettonTransfer(c echo.Context) error {
+	if s.opts.TonClient == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "ton_client_unavailable")
+	}
+	if len(s.opts.Config.MasterKey) != 32 {
+		return echo.NewHTTPError(http.StatusInternalServerError, "server_misconfiguration")
+	}
+	var payload struct {
+		UserID     int64       `json:"user_id"`
+		WalletID   int64       `json:"wallet_id"`
+		Jetton     string      `json:"jetton"`
+		To         string      `json:"to"`
+		Amount     json.Number `json:"amount"`
+		ForwardTon float64     `json:"forward_ton"`
+		ResponseTo string      `json:"response_destination"`
+		Comment    *string     `json:"comment"`
+	}
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	if payload.UserID <= 0 || payload.WalletID <= 0 || payload.ForwardTon < 0 ||
+		len(strings.TrimSpace(payload.Jetton)) < 10 || len(strings.TrimSpace(payload.To)) < 3 || payload.Amount == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	ctx := c.Request().Context()
+	row, err := s.opts.Store.GetWalletSecretByID(ctx, payload.WalletID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if row == nil || row.UserID != payload.UserID {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	mnemonic, err := crypto.DecryptMnemonic(s.opts.Config.MasterKey, row.EncryptedMnemonic)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "decrypt_failed")
+	}
+	comment := ""
+	if payload.Comment != nil {
+		comment = *payload.Comment
+	}
+	sent, err := s.opts.TonClient.TransferJetton(ctx, ton.JettonTransferRequest{
+		Mnemonic:   mnemonic,
+		Version:    ton.WalletVersion(row.Version),
+		Jetton:     payload.Jetton,
+		To:         payload.To,
+		Amount:     payload.Amount.String(),
+		ForwardTon: payload.ForwardTon,
+		Comment:    comment,
+		ResponseTo: payload.ResponseTo,
+	})
+	if err != nil {
+		if errors.Is(err, ton.ErrInvalidDestination) {
+			return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"error": "bad_to"})
+		}
+		if errors.Is(err, ton.ErrInsufficientBalance) {
+			return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"error": "insufficient"})
+		}
+		if errors.Is(err, ton.ErrInvalidJettonAmount) {
+			return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"error": "bad_amount"})
+		}
+		if errors.Is(err, ton.ErrInvalidJetton) {
+			return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"error": "bad_jetton"})
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("jetton_transfer_failed: %v", err))
+	}
+	return respondSent(c, sent)
+}
+
+// respondSent reports a broadcast transfer. It does not wait for the message
+// to land: callers poll GET /wallets/:id/transfers/:msg_hash with the
+// returned valid_until.
+func respondSent(c echo.Context, sent *ton.SentMessage) error {
+	return c.JSON(http.StatusOK, map[string]any{
+		"ok":          true,
+		"status":      "pending",
+		"msg_hash":    sent.Hash,
+		"valid_until": sent.ValidUntil.UTC(),
+	})
+}
+
+// handleTransferStatus looks up the outcome of a transfer sent from the
+// wallet: pending until its message lands or expires, then confirmed or
+// failed with the transaction result.
+func (s *Server) handleTransferStatus(c echo.Context) error {
+	if s.opts.TonClient == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "ton_client_unavailable")
+	}
+	id, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	msgHash := strings.ToLower(strings.TrimSpace(c.Param("msg_hash")))
+	if len(msgHash) != 64 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_msg_hash")
+	}
+	validUntil, err := time.Parse(time.RFC3339, strings.TrimSpace(c.QueryParam("valid_until")))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_valid_until")
+	}
+	ctx := c.Request().Context()
+	row, err := s.opts.Store.GetWalletByID(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if row == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	owner, err := address.ParseAddr(row.Address)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid_wallet_address")
+	}
+	res, err := s.opts.TonClient.FindMessageTransaction(ctx, owner, msgHash, validUntil.Add(-ton.MessageTTL))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("ton_error: %v", err))
+	}
+	out := map[string]any{"msg_hash": msgHash, "valid_until": validUntil}
+	switch {
+	case res == nil && ton.Expired(validUntil):
+		out["status"] = "expired"
+	case res == nil:
+		out["status"] = "pending"
+	case res.Success():
+		out["status"] = "confirmed"
+		out["tx"] = res
+	default:
+		out["status"] = "failed"
+		out["tx"] = res
+	}
+	return c.JSON(http.StatusOK, out)
 }
 
 func (s *Server) handleTradingProfile(c echo.Context) error {
@@ -388,12 +763,16 @@ func (s *Server) handleTradingProfileUpsert(c echo.Context) error {
 		TonAmount      *float64 `json:"ton_amount"`
 		BuyLimitPrice  *float64 `json:"buy_limit_price"`
 		SellPercent    *float64 `json:"sell_percent"`
+		Slippage       *float64 `json:"slippage_percent"`
 		TradeMode      *string  `json:"trade_mode"`
 		LastToken      *string  `json:"last_token"`
 	}
 	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
 		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
 	}
+	if payload.Slippage != nil && !validSlippage(*payload.Slippage) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_slippage")
+	}
 	if payload.ActiveWalletID != nil {
 		ctx := c.Request().Context()
 		row, err := s.opts.Store.GetWalletByID(ctx, *payload.ActiveWalletID)
@@ -406,13 +785,14 @@ func (s *Server) handleTradingProfileUpsert(c echo.Context) error {
 	}
 	ctx := c.Request().Context()
 	row, err := s.opts.Store.UpsertTradingProfile(ctx, database.TradingProfileUpdate{
-		UserID:         payload.UserID,
-		ActiveWalletID: payload.ActiveWalletID,
-		TonAmount:      payload.TonAmount,
-		BuyLimitPrice:  payload.BuyLimitPrice,
-		SellPercent:    payload.SellPercent,
-		TradeMode:      sanitizeTradeMode(payload.TradeMode),
-		LastToken:      payload.LastToken,
+		UserID:          payload.UserID,
+		ActiveWalletID:  payload.ActiveWalletID,
+		TonAmount:       payload.TonAmount,
+		BuyLimitPrice:   payload.BuyLimitPrice,
+		SellPercent:     payload.SellPercent,
+		SlippagePercent: payload.Slippage,
+		TradeMode:       sanitizeTradeMode(payload.TradeMode),
+		LastToken:       payload.LastToken,
 	})
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "upsert_failed")
@@ -422,32 +802,60 @@ func (s *Server) handleTradingProfileUpsert(c echo.Context) error {
 
 func (s *Server) handleCreateSwapOrder(c echo.Context) error {
 	var payload struct {
-		UserID       int64    `json:"user_id"`
-		WalletID     int64    `json:"wallet_id"`
-		TokenAddress string   `json:"token_address"`
-		Direction    string   `json:"direction"`
-		TonAmount    float64  `json:"ton_amount"`
-		LimitPrice   *float64 `json:"limit_price"`
-		SellPercent  *float64 `json:"sell_percent"`
+		UserID       int64      `json:"user_id"`
+		WalletID     int64      `json:"wallet_id"`
+		TokenAddress string     `json:"token_address"`
+		Direction    string     `json:"direction"`
+		Platform     string     `json:"platform"`
+		TonAmount    float64    `json:"ton_amount"`
+		LimitPrice   *float64   `json:"limit_price"`
+		ExpiresAt    *time.Time `json:"expires_at"`
+		SellPercent  *float64   `json:"sell_percent"`
+		Slippage     *float64   `json:"slippage_percent"`
+		// PositionHint only labels the position; amounts come from the
+		// relayer once the swap has settled on-chain.
 		PositionHint *struct {
-			TokenAmount   float64  `json:"token_amount"`
-			TokenPriceTon *float64 `json:"token_price_ton"`
-			TokenPriceUsd *float64 `json:"token_price_usd"`
-			TokenSymbol   *string  `json:"token_symbol"`
-			TokenName     *string  `json:"token_name"`
-			TokenImage    *string  `json:"token_image"`
+			TokenSymbol *string `json:"token_symbol"`
+			TokenName   *string `json:"token_name"`
+			TokenImage  *string `json:"token_image"`
 		} `json:"position_hint"`
 	}
 	if err := c.Bind(&payload); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
 	}
-	if payload.UserID <= 0 || payload.WalletID <= 0 || payload.TonAmount <= 0 || len(payload.TokenAddress) < 10 {
+	if payload.UserID <= 0 || payload.WalletID <= 0 || len(payload.TokenAddress) < 10 {
 		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
 	}
 	dir := strings.ToLower(payload.Direction)
-	if dir != "buy" && dir != "sell" {
+	// Buys spend a TON amount; sells spend sell_percent of the on-chain
+	// jetton balance, which the relayer reads when it executes the order.
+	var tonAmount *float64
+	switch dir {
+	case "buy":
+		if payload.TonAmount <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+		}
+		tonAmount = &payload.TonAmount
+	case "sell":
+		if payload.SellPercent == nil || *payload.SellPercent <= 0 || *payload.SellPercent > 100 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid_sell_percent")
+		}
+	default:
 		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
 	}
+	platform := sanitizePlatform(payload.Platform)
+	if platform == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported_platform")
+	}
+	if payload.LimitPrice != nil && *payload.LimitPrice <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_limit_price")
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_expires_at")
+	}
+	if payload.Slippage != nil && !validSlippage(*payload.Slippage) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_slippage")
+	}
 	ctx := c.Request().Context()
 	wallet, err := s.opts.Store.GetWalletByID(ctx, payload.WalletID)
 	if err != nil {
@@ -456,34 +864,54 @@ func (s *Server) handleCreateSwapOrder(c echo.Context) error {
 	if wallet == nil || wallet.UserID != payload.UserID {
 		return echo.NewHTTPError(http.StatusNotFound, "wallet_not_found")
 	}
-	order, err := s.opts.Store.InsertSwapOrder(ctx, database.InsertSwapOrderParams{
-		UserID:       payload.UserID,
-		WalletID:     payload.WalletID,
-		TokenAddress: payload.TokenAddress,
-		Direction:    dir,
-		TonAmount:    payload.TonAmount,
-		LimitPrice:   payload.LimitPrice,
-		SellPercent:  payload.SellPercent,
-	})
+	slippage, err := s.orderSlippage(ctx, payload.UserID, payload.Slippage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	params := database.InsertSwapOrderParams{
+		UserID:          payload.UserID,
+		WalletID:        payload.WalletID,
+		TokenAddress:    payload.TokenAddress,
+		Direction:       dir,
+		Platform:        platform,
+		TonAmount:       tonAmount,
+		LimitPrice:      payload.LimitPrice,
+		SellPercent:     payload.SellPercent,
+		ExpiresAt:       payload.ExpiresAt,
+		SlippagePercent: &slippage,
+	}
+	if hint := payload.PositionHint; hint != nil {
+		params.TokenSymbol = hint.TokenSymbol
+		params.TokenName = hint.TokenName
+		params.TokenImage = hint.TokenImage
+	}
+	order, err := s.opts.Store.InsertSwapOrder(ctx, params)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "insert_failed")
 	}
-	if dir == "buy" && payload.PositionHint != nil && payload.PositionHint.TokenAmount > 0 {
-		_, upsertErr := s.opts.Store.UpsertUserPosition(ctx, database.UpsertUserPositionParams{
-			UserID:       payload.UserID,
-			WalletID:     payload.WalletID,
-			TokenAddress: payload.TokenAddress,
-			TokenSymbol:  payload.PositionHint.TokenSymbol,
-			TokenName:    payload.PositionHint.TokenName,
-			TokenImage:   payload.PositionHint.TokenImage,
-			Amount:       payload.PositionHint.TokenAmount,
-			InvestedTon:  payload.TonAmount,
-		})
-		if upsertErr != nil {
-			c.Logger().Errorf("position upsert failed: %v", upsertErr)
+	return c.JSON(http.StatusOK, map[string]any{"order": order})
+}
+
+// orderSlippage resolves the slippage bound for a new order: explicit value,
+// then the user's trading profile, then the server default.
+func (s *Server) orderSlippage(ctx context.Context, userID int64, explicit *float64) (float64, error) {
+	if explicit != nil {
+		return *explicit, nil
+	}
+	profile, err := s.opts.Store.GetTradingProfile(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if profile != nil && profile.Slippage != nil {
+		if v, err := strconv.ParseFloat(*profile.Slippage, 64); err == nil && validSlippage(v) {
+			return v, nil
 		}
 	}
-	return c.JSON(http.StatusOK, map[string]any{"order": order})
+	return s.opts.Config.DefaultSlippage, nil
+}
+
+func validSlippage(v float64) bool {
+	return v > 0 && v <= 100
 }
 
 func (s *Server) handleListAllUserWallets(c echo.Context) error {
@@ -536,6 +964,328 @@ func (s *Server) handleHidePosition(c echo.Context) error {
 	return c.JSON(http.StatusOK, row)
 }
 
+// handleCreatePositionTrigger adds a take-profit or stop-loss rule that the
+// relayer's price job turns into a sell order once crossed.
+func (s *Server) handleCreatePositionTrigger(c echo.Context) error {
+	positionID, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	var payload struct {
+		UserID        int64    `json:"user_id"`
+		Kind          string   `json:"kind"`
+		ThresholdType string   `json:"threshold_type"`
+		Threshold     float64  `json:"threshold"`
+		SellPercent   *float64 `json:"sell_percent"`
+		Slippage      *float64 `json:"slippage_percent"`
+	}
+	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	kind := strings.ToLower(strings.TrimSpace(payload.Kind))
+	if kind != "take_profit" && kind != "stop_loss" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_kind")
+	}
+	thresholdType := strings.ToLower(strings.TrimSpace(payload.ThresholdType))
+	if thresholdType == "" {
+		thresholdType = "percent"
+	}
+	if thresholdType != "percent" && thresholdType != "value" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_threshold_type")
+	}
+	if payload.Threshold <= 0 || (kind == "stop_loss" && thresholdType == "percent" && payload.Threshold >= 100) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_threshold")
+	}
+	sellPercent := 100.0
+	if payload.SellPercent != nil {
+		sellPercent = *payload.SellPercent
+	}
+	if sellPercent <= 0 || sellPercent > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_sell_percent")
+	}
+	if payload.Slippage != nil && !validSlippage(*payload.Slippage) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_slippage")
+	}
+	ctx := c.Request().Context()
+	slippage, err := s.orderSlippage(ctx, payload.UserID, payload.Slippage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	row, err := s.opts.Store.InsertPositionTrigger(ctx, database.InsertPositionTriggerParams{
+		UserID:          payload.UserID,
+		PositionID:      positionID,
+		Kind:            kind,
+		ThresholdType:   thresholdType,
+		Threshold:       payload.Threshold,
+		SellPercent:     sellPercent,
+		SlippagePercent: &slippage,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "insert_failed")
+	}
+	if row == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"trigger": row})
+}
+
+func (s *Server) handleListPositionTriggers(c echo.Context) error {
+	userID, err := parseInt64(c.QueryParam("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id required")
+	}
+	rows, err := s.opts.Store.ListPositionTriggers(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+func (s *Server) handleCancelPositionTrigger(c echo.Context) error {
+	triggerID, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	var payload struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	row, err := s.opts.Store.CancelPositionTrigger(c.Request().Context(), payload.UserID, triggerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "update_failed")
+	}
+	if row == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"trigger": row})
+}
+
+// handleCreateTrailingStop places a trailing stop on a token held by one of
+// the user's wallets.
+func (s *Server) handleCreateTrailingStop(c echo.Context) error {
+	var payload struct {
+		UserID       int64    `json:"user_id"`
+		WalletID     int64    `json:"wallet_id"`
+		TokenAddress string   `json:"token_address"`
+		Platform     string   `json:"platform"`
+		TrailPercent float64  `json:"trail_percent"`
+		SellPercent  *float64 `json:"sell_percent"`
+		Slippage     *float64 `json:"slippage_percent"`
+	}
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	if payload.UserID <= 0 || payload.WalletID <= 0 || len(payload.TokenAddress) < 10 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	if payload.TrailPercent <= 0 || payload.TrailPercent >= 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_trail_percent")
+	}
+	sellPercent := 100.0
+	if payload.SellPercent != nil {
+		sellPercent = *payload.SellPercent
+	}
+	if sellPercent <= 0 || sellPercent > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_sell_percent")
+	}
+	if payload.Slippage != nil && !validSlippage(*payload.Slippage) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_slippage")
+	}
+	platform := sanitizePlatform(payload.Platform)
+	if platform == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported_platform")
+	}
+	ctx := c.Request().Context()
+	wallet, err := s.opts.Store.GetWalletByID(ctx, payload.WalletID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if wallet == nil || wallet.UserID != payload.UserID {
+		return echo.NewHTTPError(http.StatusNotFound, "wallet_not_found")
+	}
+	slippage, err := s.orderSlippage(ctx, payload.UserID, payload.Slippage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	row, err := s.opts.Store.InsertTrailingStop(ctx, database.InsertTrailingStopParams{
+		UserID:          payload.UserID,
+		WalletID:        payload.WalletID,
+		TokenAddress:    payload.TokenAddress,
+		Platform:        platform,
+		TrailPercent:    payload.TrailPercent,
+		SellPercent:     sellPercent,
+		SlippagePercent: &slippage,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "insert_failed")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"trailing_stop": row})
+}
+
+func (s *Server) handleListTrailingStops(c echo.Context) error {
+	userID, err := parseInt64(c.QueryParam("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id required")
+	}
+	rows, err := s.opts.Store.ListTrailingStops(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+func (s *Server) handleCancelTrailingStop(c echo.Context) error {
+	stopID, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	var payload struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	row, err := s.opts.Store.CancelTrailingStop(c.Request().Context(), payload.UserID, stopID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "update_failed")
+	}
+	if row == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"trailing_stop": row})
+}
+
+// minDCAInterval keeps plans from turning into a high-frequency bot.
+const minDCAInterval = time.Minute
+
+// handleCreateDCAPlan schedules recurring buys; each run becomes an ordinary
+// swap order picked up by the relayer.
+func (s *Server) handleCreateDCAPlan(c echo.Context) error {
+	var payload struct {
+		UserID       int64      `json:"user_id"`
+		WalletID     int64      `json:"wallet_id"`
+		TokenAddress string     `json:"token_address"`
+		Platform     string     `json:"platform"`
+		TonAmount    float64    `json:"ton_amount"`
+		Interval     string     `json:"interval"`
+		Runs         int        `json:"runs"`
+		Slippage     *float64   `json:"slippage_percent"`
+		StartAt      *time.Time `json:"start_at"`
+	}
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	if payload.UserID <= 0 || payload.WalletID <= 0 || len(payload.TokenAddress) < 10 || payload.TonAmount <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+	}
+	interval, err := time.ParseDuration(strings.TrimSpace(payload.Interval))
+	if err != nil || interval < minDCAInterval {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_interval")
+	}
+	if payload.Runs <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_runs")
+	}
+	if payload.Slippage != nil && !validSlippage(*payload.Slippage) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid_slippage")
+	}
+	platform := sanitizePlatform(payload.Platform)
+	if platform == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported_platform")
+	}
+	ctx := c.Request().Context()
+	wallet, err := s.opts.Store.GetWalletByID(ctx, payload.WalletID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if wallet == nil || wallet.UserID != payload.UserID {
+		return echo.NewHTTPError(http.StatusNotFound, "wallet_not_found")
+	}
+	slippage, err := s.orderSlippage(ctx, payload.UserID, payload.Slippage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	row, err := s.opts.Store.InsertDCAPlan(ctx, database.InsertDCAPlanParams{
+		UserID:          payload.UserID,
+		WalletID:        payload.WalletID,
+		TokenAddress:    payload.TokenAddress,
+		Platform:        platform,
+		TonAmount:       payload.TonAmount,
+		SlippagePercent: &slippage,
+		Interval:        interval,
+		TotalRuns:       payload.Runs,
+		StartAt:         payload.StartAt,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "insert_failed")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"plan": row})
+}
+
+func (s *Server) handleListDCAPlans(c echo.Context) error {
+	userID, err := parseInt64(c.QueryParam("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id required")
+	}
+	rows, err := s.opts.Store.ListDCAPlans(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+func (s *Server) handleGetDCAPlan(c echo.Context) error {
+	planID, err := parseInt64(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "id required")
+	}
+	userID, err := parseInt64(c.QueryParam("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id required")
+	}
+	row, err := s.opts.Store.GetDCAPlan(c.Request().Context(), planID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+	}
+	if row == nil || row.UserID != userID {
+		return echo.NewHTTPError(http.StatusNotFound, "not_found")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"plan": row})
+}
+
+// handleSetDCAPlanStatus pauses, resumes or cancels a user's plan.
+func (s *Server) handleSetDCAPlanStatus(status string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		planID, err := parseInt64(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "id required")
+		}
+		var payload struct {
+			UserID int64 `json:"user_id"`
+		}
+		if err := c.Bind(&payload); err != nil || payload.UserID <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "bad_request")
+		}
+		ctx := c.Request().Context()
+		row, err := s.opts.Store.SetDCAPlanStatus(ctx, payload.UserID, planID, status)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "update_failed")
+		}
+		if row != nil {
+			return c.JSON(http.StatusOK, map[string]any{"plan": row})
+		}
+		current, err := s.opts.Store.GetDCAPlan(ctx, planID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "fetch_failed")
+		}
+		if current == nil || current.UserID != payload.UserID {
+			return echo.NewHTTPError(http.StatusNotFound, "not_found")
+		}
+		return echo.NewHTTPError(http.StatusConflict, "plan_finished")
+	}
+}
+
 func (s *Server) fetchBalance(ctx context.Context, address string) (*ton.Balance, error) {
 	if s.opts.TonClient == nil {
 		return nil, errors.New("ton client unavailable")
@@ -570,3 +1320,18 @@ func sanitizeTradeMode(mode *string) *string {
 	}
 	return &m
 }
+
+// sanitizePlatform normalizes the DEX name; empty input defaults to DeDust.
+func sanitizePlatform(platform string) string {
+	p := strings.ToLower(strings.TrimSpace(platform))
+	switch p {
+	case "":
+		return "dedust"
+	case "dedust", "stonfi", "tonfun", "gaspump", "memeslab", "blum":
+		return p
+	case "ston", "ston.fi":
+		return "stonfi"
+	default:
+		return ""
+	}
+}
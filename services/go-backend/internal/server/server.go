@@ -5,21 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/xssnick/tonutils-go/address"
+
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/config"
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/database"
+	"github.com/qtosh1/ton-bot/services/go-backend/internal/relayer"
 	"github.com/qtosh1/ton-bot/services/go-backend/internal/ton"
 )
 
 // TonService captures the Ton-related operations required by the HTTP layer.
 type TonService interface {
 	Ping(ctx context.Context) error
+	Endpoints() []ton.EndpointStatus
 	GetAccountBalance(ctx context.Context, address string) (*ton.Balance, error)
 	EstimateMaxSendable(ctx context.Context, address string) (*ton.MaxSendable, error)
-	DeriveWalletAddress(words []string) (string, error)
-	Transfer(ctx context.Context, req ton.TransferRequest) error
+	DeriveWalletAddress(words []string, version ton.WalletVersion) (string, error)
+	Transfer(ctx context.Context, req ton.TransferRequest) (*ton.SentMessage, error)
+	TransferJetton(ctx context.Context, req ton.JettonTransferRequest) (*ton.SentMessage, error)
+	FindMessageTransaction(ctx context.Context, wallet *address.Address, msgHash string, since time.Time) (*ton.TxResult, error)
+	GetJettonBalances(ctx context.Context, owner *address.Address, masters []*address.Address) ([]ton.JettonBalance, error)
+	GetTransactions(ctx context.Context, wallet *address.Address, cursor *ton.TxCursor, limit int) ([]ton.WalletTransaction, *ton.TxCursor, error)
+}
+
+// RelayerStatus exposes the in-process swap relayer state.
+type RelayerStatus interface {
+	Status(ctx context.Context) (*relayer.Status, error)
 }
 
 // Options configures the HTTP server instance.
@@ -27,6 +41,8 @@ type Options struct {
 	Config    config.Config
 	Store     *database.Store
 	TonClient TonService
+	// Relayer is nil when the Go relayer is disabled.
+	Relayer RelayerStatus
 }
 
 // Server wires Echo with the application dependencies.
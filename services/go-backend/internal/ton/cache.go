@@ -0,0 +1,131 @@
+package ton
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// DefaultCacheTTL is how long balance and wallet state reads are reused when
+// Config.CacheTTL is zero.
+const DefaultCacheTTL = 3 * time.Second
+
+// sharedCallTimeout bounds a coalesced call, which runs detached from the
+// callers that joined it so one of them leaving does not fail the rest.
+const sharedCallTimeout = 30 * time.Second
+
+// cachedMethods are the account state reads served from the response cache,
+// keyed by address.
+var cachedMethods = map[string]bool{
+	"getAddressBalance":     true,
+	"getAddressInformation": true,
+	"getWalletInformation":  true,
+}
+
+// maxCacheEntries triggers a sweep of expired entries on insert.
+const maxCacheEntries = 1024
+
+// responseCache keeps raw responses of account state reads for a short TTL.
+type responseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	account string // raw address the entry describes
+	data    []byte
+	expires time.Time
+}
+
+// newResponseCache returns nil (no caching) for a negative ttl.
+func newResponseCache(ttl time.Duration) *responseCache {
+	if ttl < 0 {
+		return nil
+	}
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &responseCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+func (rc *responseCache) get(key string) ([]byte, bool) {
+	if rc == nil {
+		return nil, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+func (rc *responseCache) put(key, account string, data []byte) {
+	if rc == nil {
+		return
+	}
+	now := time.Now()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.entries) >= maxCacheEntries {
+		for k, entry := range rc.entries {
+			if now.After(entry.expires) {
+				delete(rc.entries, k)
+			}
+		}
+	}
+	rc.entries[key] = cacheEntry{account: account, data: data, expires: now.Add(rc.ttl)}
+}
+
+// forget drops every entry about account.
+func (rc *responseCache) forget(account string) {
+	if rc == nil || account == "" {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for k, entry := range rc.entries {
+		if entry.account == account {
+			delete(rc.entries, k)
+		}
+	}
+}
+
+// cacheAccount normalizes an address so user-friendly and raw forms of one
+// account share cache entries.
+func cacheAccount(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if parsed, err := address.ParseAddr(addr); err == nil {
+		return parsed.StringRaw()
+	}
+	if parsed, err := address.ParseRawAddr(addr); err == nil {
+		return parsed.StringRaw()
+	}
+	return addr
+}
+
+// shared runs fn once for all concurrent callers with the same key and hands
+// each of them the result; a caller whose ctx ends stops waiting without
+// cancelling the call for the others.
+func (c *Client) shared(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	ch := c.flight.DoChan(key, func() (any, error) {
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		return fn(callCtx)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
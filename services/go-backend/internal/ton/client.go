@@ -5,36 +5,61 @@ import (
 	"context"
 	"crypto/ed25519"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"math"
 	"math/big"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton/wallet"
+	"golang.org/x/sync/singleflight"
 )
 
 // Config describes Ton endpoint settings.
 type Config struct {
+	// Endpoints lists providers in order of preference; requests fail over
+	// between them. Endpoint and APIKey describe the only provider when
+	// Endpoints is empty.
+	Endpoints  []Endpoint
 	Endpoint   string
 	APIKey     string
 	HTTPClient *http.Client
+	// RateLimit caps requests per second across all endpoints (0 means no
+	// limit); RateBurst defaults to one second worth of requests.
+	RateLimit float64
+	RateBurst int
+	// CacheTTL is how long balance and wallet state reads are reused;
+	// DefaultCacheTTL when zero, no caching when negative.
+	CacheTTL time.Duration
+	// HighloadQueryIDs supplies query ids for highload v3 wallets; a
+	// process-local counter is used when nil.
+	HighloadQueryIDs QueryIDSource
 }
 
 // Client is a thin wrapper over TON Center HTTP APIs.
 type Client struct {
-	endpoint string
-	restBase string
-	apiKey   string
-	http     *http.Client
+	endpoints []*endpoint
+	http      *http.Client
+	queryIDs  QueryIDSource
+
+	pinsMu sync.Mutex
+	pins   map[string]pinnedEndpoint // by external message hash
+
+	limiter *limiter
+	flight  singleflight.Group // coalesces identical in-flight reads
+	cache   *responseCache
+
+	decimalsMu sync.Mutex
+	decimals   map[string]int
 }
 
 // NewClient constructs a Ton client helper.
@@ -43,22 +68,34 @@ func NewClient(cfg Config) *Client {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: 10 * time.Second}
 	}
-	base := strings.TrimRight(cfg.Endpoint, "/")
-	rest := base
-	if strings.HasSuffix(strings.ToLower(rest), "/jsonrpc") {
-		rest = strings.TrimSuffix(rest, "/jsonrpc")
+	queryIDs := cfg.HighloadQueryIDs
+	if queryIDs == nil {
+		queryIDs = localQueryIDs()
+	}
+	configured := cfg.Endpoints
+	if len(configured) == 0 && strings.TrimSpace(cfg.Endpoint) != "" {
+		configured = []Endpoint{{URL: cfg.Endpoint, APIKey: cfg.APIKey}}
+	}
+	var endpoints []*endpoint
+	for _, ep := range configured {
+		if strings.TrimSpace(ep.URL) != "" {
+			endpoints = append(endpoints, newEndpoint(ep))
+		}
 	}
 	return &Client{
-		endpoint: base,
-		restBase: strings.TrimRight(rest, "/"),
-		apiKey:   strings.TrimSpace(cfg.APIKey),
-		http:     httpClient,
+		endpoints: endpoints,
+		http:      httpClient,
+		queryIDs:  queryIDs,
+		pins:      make(map[string]pinnedEndpoint),
+		limiter:   newLimiter(cfg.RateLimit, cfg.RateBurst),
+		cache:     newResponseCache(cfg.CacheTTL),
+		decimals:  make(map[string]int),
 	}
 }
 
-// Ping verifies that the configured endpoint looks sane.
+// Ping verifies that a configured endpoint looks sane.
 func (c *Client) Ping(ctx context.Context) error {
-	if c.restBase == "" {
+	if len(c.endpoints) == 0 {
 		return errors.New("ton endpoint is not configured")
 	}
 	var resp tonTimeResponse
@@ -90,6 +127,8 @@ type TransferRequest struct {
 	AmountTon float64
 	Comment   string
 	Bounce    bool
+	// Version is the sender's wallet version (V4R2 when empty).
+	Version WalletVersion
 }
 
 var ErrNotImplemented = errors.New("ton client: not implemented")
@@ -140,14 +179,19 @@ func (c *Client) EstimateMaxSendable(ctx context.Context, addr string) (*MaxSend
 	}, nil
 }
 
-// DeriveWalletAddress converts mnemonic words to an address.
-func (c *Client) DeriveWalletAddress(words []string) (string, error) {
+// DeriveWalletAddress converts mnemonic words to the address of a wallet of
+// the given version.
+func (c *Client) DeriveWalletAddress(words []string, version WalletVersion) (string, error) {
+	cfg, err := versionConfig(version, c.queryIDs)
+	if err != nil {
+		return "", err
+	}
 	priv, err := wallet.SeedToPrivateKey(words, "", false)
 	if err != nil {
 		return "", err
 	}
 	pub := priv.Public().(ed25519.PublicKey)
-	addr, err := wallet.AddressFromPubKey(pub, wallet.V4R2, wallet.DefaultSubwallet)
+	addr, err := wallet.AddressFromPubKey(pub, cfg, wallet.DefaultSubwallet)
 	if err != nil {
 		return "", err
 	}
@@ -155,46 +199,38 @@ func (c *Client) DeriveWalletAddress(words []string) (string, error) {
 }
 
 // Transfer pushes an outgoing transfer on behalf of mnemonic.
-func (c *Client) Transfer(ctx context.Context, req TransferRequest) error {
+func (c *Client) Transfer(ctx context.Context, req TransferRequest) (*SentMessage, error) {
 	if strings.TrimSpace(req.Mnemonic) == "" {
-		return fmt.Errorf("mnemonic is required")
+		return nil, fmt.Errorf("mnemonic is required")
 	}
 	destAddr, err := address.ParseAddr(strings.TrimSpace(req.To))
 	if err != nil {
-		return ErrInvalidDestination
-	}
-	words := strings.Fields(req.Mnemonic)
-	if len(words) == 0 {
-		return fmt.Errorf("mnemonic is required")
-	}
-	priv, err := wallet.SeedToPrivateKey(words, "", false)
-	if err != nil {
-		return fmt.Errorf("mnemonic decode failed: %w", err)
+		return nil, ErrInvalidDestination
 	}
-	contract, err := wallet.FromPrivateKey(nil, priv, wallet.V4R2)
+	contract, err := c.OpenWallet(req.Mnemonic, req.Version)
 	if err != nil {
-		return fmt.Errorf("init wallet: %w", err)
+		return nil, err
 	}
 	fromAddr := contract.WalletAddress().String()
-	walletInfo, err := c.loadWalletInfo(ctx, fromAddr)
+	walletInfo, err := c.seqnoInfo(ctx, contract)
 	if err != nil {
-		return fmt.Errorf("wallet info: %w", err)
+		return nil, err
 	}
 	addrInfo, err := c.loadAddressInfo(ctx, fromAddr)
 	if err != nil {
-		return fmt.Errorf("address info: %w", err)
+		return nil, fmt.Errorf("address info: %w", err)
 	}
 	balance, err := c.GetAccountBalance(ctx, fromAddr)
 	if err != nil {
-		return fmt.Errorf("wallet balance: %w", err)
+		return nil, fmt.Errorf("wallet balance: %w", err)
 	}
 	amountCoins, err := coinsFromFloat(req.AmountTon)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	balanceNano := parseBigInt(balance.Nano)
 	if balanceNano == nil {
-		return fmt.Errorf("invalid balance")
+		return nil, fmt.Errorf("invalid balance")
 	}
 	stateActive := addrInfo != nil && strings.EqualFold(addrInfo.State, "active")
 	reserve := big.NewInt(20_000_000)
@@ -203,9 +239,98 @@ func (c *Client) Transfer(ctx context.Context, req TransferRequest) error {
 	}
 	required := new(big.Int).Add(amountCoins.Nano(), reserve)
 	if balanceNano.Cmp(required) < 0 {
-		return ErrInsufficientBalance
+		return nil, ErrInsufficientBalance
+	}
+	msg, err := contract.BuildTransfer(destAddr, amountCoins, req.Bounce, req.Comment)
+	if err != nil {
+		return nil, fmt.Errorf("build transfer: %w", err)
+	}
+	return c.signAndBroadcast(ctx, contract, walletInfo, stateActive, []*wallet.Message{msg})
+}
+
+// SendMessages signs internal messages with contract and broadcasts them.
+func (c *Client) SendMessages(ctx context.Context, contract *wallet.Wallet, msgs []*wallet.Message) (*SentMessage, error) {
+	prepared, err := c.PrepareMessages(ctx, contract, msgs)
+	if err != nil {
+		return nil, err
 	}
-	if spec, ok := contract.GetSpec().(*wallet.SpecV4R2); ok {
+	return c.SendPrepared(ctx, prepared)
+}
+
+// PrepareMessages signs internal messages with contract without sending
+// them, so callers can persist the message hash before it hits the network.
+func (c *Client) PrepareMessages(ctx context.Context, contract *wallet.Wallet, msgs []*wallet.Message) (*PreparedMessage, error) {
+	state, err := c.LoadWalletState(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+	return c.SignMessages(ctx, contract, state, msgs)
+}
+
+// WalletState is the on-chain state a message is signed against: the seqno
+// and whether the wallet still needs its state init.
+type WalletState struct {
+	info   *tonWalletInfo
+	active bool
+}
+
+// LoadWalletState reads what SignMessages needs from the network.
+func (c *Client) LoadWalletState(ctx context.Context, contract *wallet.Wallet) (*WalletState, error) {
+	walletInfo, err := c.seqnoInfo(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+	addrInfo, err := c.loadAddressInfo(ctx, contract.WalletAddress().String())
+	if err != nil {
+		return nil, fmt.Errorf("address info: %w", err)
+	}
+	return &WalletState{info: walletInfo, active: addrInfo != nil && strings.EqualFold(addrInfo.State, "active")}, nil
+}
+
+// SignMessages signs internal messages against a state from
+// LoadWalletState without touching the network, so callers can sign while
+// holding database locks.
+func (c *Client) SignMessages(ctx context.Context, contract *wallet.Wallet, state *WalletState, msgs []*wallet.Message) (*PreparedMessage, error) {
+	return c.prepare(ctx, contract, state.info, state.active, msgs)
+}
+
+// SendPrepared broadcasts a message built by PrepareMessages. The provider
+// that accepts it also serves the message's confirmation lookups.
+func (c *Client) SendPrepared(ctx context.Context, prepared *PreparedMessage) (*SentMessage, error) {
+	ep, err := c.broadcast(ctx, prepared.BOC, c.pinned(prepared.Hash))
+	if err != nil {
+		return nil, err
+	}
+	c.pin(prepared.Hash, ep)
+	c.cache.forget(prepared.Wallet.StringRaw())
+	sent := prepared.SentMessage
+	return &sent, nil
+}
+
+// seqnoInfo loads the wallet state that carries the seqno; highload wallets
+// do not use one and skip the request.
+func (c *Client) seqnoInfo(ctx context.Context, contract *wallet.Wallet) (*tonWalletInfo, error) {
+	if _, ok := contract.GetSpec().(seqnoSpec); !ok {
+		return nil, nil
+	}
+	info, err := c.loadWalletInfo(ctx, contract.WalletAddress().String())
+	if err != nil {
+		return nil, fmt.Errorf("wallet info: %w", err)
+	}
+	return info, nil
+}
+
+func (c *Client) signAndBroadcast(ctx context.Context, contract *wallet.Wallet, walletInfo *tonWalletInfo, stateActive bool, msgs []*wallet.Message) (*SentMessage, error) {
+	prepared, err := c.prepare(ctx, contract, walletInfo, stateActive, msgs)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendPrepared(ctx, prepared)
+}
+
+func (c *Client) prepare(ctx context.Context, contract *wallet.Wallet, walletInfo *tonWalletInfo, stateActive bool, msgs []*wallet.Message) (*PreparedMessage, error) {
+	// Highload wallets have no seqno; their spec carries its own query ids.
+	if spec, ok := contract.GetSpec().(seqnoSpec); ok {
 		seqno := uint32(0)
 		if walletInfo != nil && walletInfo.Seqno >= 0 {
 			seqno = uint32(walletInfo.Seqno)
@@ -213,31 +338,40 @@ func (c *Client) Transfer(ctx context.Context, req TransferRequest) error {
 		spec.SetSeqnoFetcher(func(ctx context.Context, subWallet uint32) (uint32, error) {
 			return seqno, nil
 		})
+		spec.SetMessagesTTL(uint32(MessageTTL / time.Second))
 	}
-	msg, err := contract.BuildTransfer(destAddr, amountCoins, req.Bounce, req.Comment)
-	if err != nil {
-		return fmt.Errorf("build transfer: %w", err)
-	}
+	sentAt := time.Now()
 	withStateInit := !stateActive
-	ext, err := contract.PrepareExternalMessageForMany(ctx, withStateInit, []*wallet.Message{msg})
+	ext, err := contract.PrepareExternalMessageForMany(ctx, withStateInit, msgs)
 	if err != nil {
-		return fmt.Errorf("prepare message: %w", err)
+		return nil, fmt.Errorf("prepare message: %w", err)
 	}
 	root, err := tlb.ToCell(ext)
 	if err != nil {
-		return fmt.Errorf("encode message: %w", err)
-	}
-	boc := base64.StdEncoding.EncodeToString(root.ToBOC())
-	return c.BroadcastBoc(ctx, boc)
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+	return &PreparedMessage{
+		SentMessage: SentMessage{
+			Wallet:     contract.WalletAddress(),
+			Hash:       hex.EncodeToString(root.Hash()),
+			SentAt:     sentAt,
+			ValidUntil: sentAt.Add(MessageTTL),
+		},
+		BOC: base64.StdEncoding.EncodeToString(root.ToBOC()),
+	}, nil
 }
 
-// BroadcastBoc sends a signed BOC via Toncenter JSON-RPC.
+// BroadcastBoc sends a signed BOC via Toncenter JSON-RPC. Re-sending the
+// same BOC to another provider after a failed attempt is harmless: the
+// wallet processes an external message at most once.
 func (c *Client) BroadcastBoc(ctx context.Context, boc string) error {
+	_, err := c.broadcast(ctx, boc, nil)
+	return err
+}
+
+func (c *Client) broadcast(ctx context.Context, boc string, prefer *endpoint) (*endpoint, error) {
 	if strings.TrimSpace(boc) == "" {
-		return fmt.Errorf("boc payload is empty")
-	}
-	if c.endpoint == "" {
-		return errors.New("ton rpc endpoint not configured")
+		return nil, fmt.Errorf("boc payload is empty")
 	}
 	payload := map[string]any{
 		"jsonrpc": "2.0",
@@ -249,36 +383,30 @@ func (c *Client) BroadcastBoc(ctx context.Context, boc string) error {
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return err
-	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		req.Header.Set("X-API-Key", c.apiKey)
-	}
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	data, ep, err := c.roundTrip(ctx, "sendTransaction", priorityBroadcast, prefer, func(ep *endpoint) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.rpc, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if ep.apiKey != "" {
+			req.Header.Set("X-API-Key", ep.apiKey)
+		}
+		return req, nil
+	})
 	if err != nil {
-		return err
-	}
-	if resp.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("sendTransaction failed: status %d body %s", resp.StatusCode, string(data))
+		return nil, err
 	}
 	var rpcResp rpcResponse
 	if err := json.Unmarshal(data, &rpcResp); err != nil {
-		return fmt.Errorf("decode rpc response: %w", err)
+		return nil, fmt.Errorf("decode rpc response: %w", err)
 	}
 	if rpcResp.Error != nil {
-		return fmt.Errorf("sendTransaction rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, fmt.Errorf("sendTransaction rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
 	}
-	return nil
+	return ep, nil
 }
 
 func (c *Client) loadAddressInfo(ctx context.Context, addr string) (*tonAddressInfo, error) {
@@ -292,9 +420,11 @@ func (c *Client) loadAddressInfo(ctx context.Context, addr string) (*tonAddressI
 	return &resp.Result, nil
 }
 
+// loadWalletInfo always reads fresh state: it feeds the seqno of messages
+// about to be signed.
 func (c *Client) loadWalletInfo(ctx context.Context, addr string) (*tonWalletInfo, error) {
 	var resp tonWalletInfoResponse
-	if err := c.call(ctx, "getWalletInformation", url.Values{"address": {addr}}, &resp); err != nil {
+	if err := c.read(ctx, "getWalletInformation", url.Values{"address": {addr}}, &resp, readOpts{fresh: true}); err != nil {
 		return nil, err
 	}
 	if !resp.Ok {
@@ -304,34 +434,79 @@ func (c *Client) loadWalletInfo(ctx context.Context, addr string) (*tonWalletInf
 }
 
 func (c *Client) call(ctx context.Context, method string, params url.Values, dest any) error {
-	if c.restBase == "" {
-		return errors.New("ton endpoint not configured")
+	return c.read(ctx, method, params, dest, readOpts{})
+}
+
+// readOpts tunes one REST read.
+type readOpts struct {
+	prefer *endpoint // tried first
+	fresh  bool      // bypass the response cache
+}
+
+// read performs a REST GET. Identical concurrent reads share one request, and
+// account state reads are served from the response cache.
+func (c *Client) read(ctx context.Context, method string, params url.Values, dest any, opts readOpts) error {
+	key := method + "?" + params.Encode()
+	var account string
+	if cachedMethods[method] {
+		account = cacheAccount(params.Get("address"))
+		key = method + "?address=" + account
+		if !opts.fresh {
+			if data, ok := c.cache.get(key); ok {
+				return json.Unmarshal(data, dest)
+			}
+		}
 	}
-	u, err := url.Parse(c.restBase + "/" + method)
+	data, err := c.shared(ctx, key, func(ctx context.Context) ([]byte, error) {
+		data, _, err := c.roundTrip(ctx, method, priorityRead, opts.prefer, func(ep *endpoint) (*http.Request, error) {
+			u, err := url.Parse(ep.rest + "/" + method)
+			if err != nil {
+				return nil, err
+			}
+			query := url.Values{}
+			for k, v := range params {
+				query[k] = v
+			}
+			if ep.apiKey != "" {
+				query.Set("api_key", ep.apiKey)
+			}
+			u.RawQuery = query.Encode()
+			return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		})
+		if err == nil && account != "" {
+			c.cache.put(key, account, data)
+		}
+		return data, err
+	})
 	if err != nil {
 		return err
 	}
-	if params == nil {
-		params = url.Values{}
-	}
-	if c.apiKey != "" {
-		params.Set("api_key", c.apiKey)
-	}
-	u.RawQuery = params.Encode()
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	return json.Unmarshal(data, dest)
+}
+
+func (c *Client) post(ctx context.Context, method string, payload any, dest any) error {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	resp, err := c.http.Do(req)
+	data, err := c.shared(ctx, method+" "+string(body), func(ctx context.Context) ([]byte, error) {
+		data, _, err := c.roundTrip(ctx, method, priorityRead, nil, func(ep *endpoint) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.rest+"/"+method, bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if ep.apiKey != "" {
+				req.Header.Set("X-API-Key", ep.apiKey)
+			}
+			return req, nil
+		})
+		return data, err
+	})
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= http.StatusBadRequest {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-		return fmt.Errorf("ton request %s failed: status %d body %s", method, resp.StatusCode, string(body))
-	}
-	return json.NewDecoder(resp.Body).Decode(dest)
+	return json.Unmarshal(data, dest)
 }
 
 func parseBigInt(value string) *big.Int {
@@ -351,15 +526,23 @@ func formatTonString(nano string) string {
 }
 
 func formatBigTon(n *big.Int) string {
+	return formatUnits(n, 9)
+}
+
+// formatUnits renders a raw integer amount with the given number of decimals.
+func formatUnits(n *big.Int, decimals int) string {
 	negative := n.Sign() < 0
 	val := new(big.Int).Set(n)
 	if negative {
 		val.Neg(val)
 	}
-	denom := big.NewInt(1_000_000_000)
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
 	intPart := new(big.Int).Quo(val, denom)
 	frac := new(big.Int).Mod(val, denom)
-	fracStr := fmt.Sprintf("%09s", frac.Text(10))
+	fracStr := ""
+	if decimals > 0 {
+		fracStr = fmt.Sprintf("%0*s", decimals, frac.Text(10))
+	}
 	fracStr = strings.TrimRight(fracStr, "0")
 	result := intPart.Text(10)
 	if fracStr != "" {
@@ -0,0 +1,338 @@
+package ton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is one Toncenter-compatible RPC provider.
+type Endpoint struct {
+	// URL is the JSON-RPC URL; REST methods are called next to it with the
+	// /jsonRPC suffix stripped.
+	URL    string
+	APIKey string
+}
+
+// ParseEndpoints splits a comma-separated list of endpoint URLs. An entry may
+// carry its own API key as "url|key"; entries without one use apiKey.
+func ParseEndpoints(list, apiKey string) []Endpoint {
+	var out []Endpoint
+	for _, entry := range strings.Split(list, ",") {
+		rawURL, key, ok := strings.Cut(strings.TrimSpace(entry), "|")
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" {
+			continue
+		}
+		if !ok {
+			key = apiKey
+		}
+		out = append(out, Endpoint{URL: rawURL, APIKey: strings.TrimSpace(key)})
+	}
+	return out
+}
+
+const (
+	// endpointScoreWeight is the EWMA weight of the latest outcome.
+	endpointScoreWeight = 0.2
+	// endpointScoreRecovery is the half-life over which an idle endpoint's
+	// score drifts back to healthy, so a demoted provider gets traffic again.
+	endpointScoreRecovery = time.Minute
+	// endpointHealthy is the score above which endpoints keep their
+	// configured order instead of being ranked by score.
+	endpointHealthy       = 0.7
+	endpointCooldownBase  = 2 * time.Second
+	endpointCooldownMax   = 2 * time.Minute
+	maxResponseBody       = 16 << 20
+	maxErrorBody          = 2048
+	broadcastStickyWindow = MessageTTL + confirmLatency
+)
+
+// endpoint tracks the health of one provider. score is an EWMA of request
+// outcomes (1 success, 0 failure); consecutive failures put the endpoint in
+// an exponentially growing cooldown during which it is only tried last.
+type endpoint struct {
+	rpc    string
+	rest   string
+	apiKey string
+
+	mu        sync.Mutex
+	score     float64
+	updatedAt time.Time
+	latency   time.Duration // EWMA of successful round trips
+	failures  int           // consecutive
+	downUntil time.Time
+	lastErr   string
+}
+
+func newEndpoint(cfg Endpoint) *endpoint {
+	base := strings.TrimRight(strings.TrimSpace(cfg.URL), "/")
+	rest := base
+	if strings.HasSuffix(strings.ToLower(rest), "/jsonrpc") {
+		rest = rest[:len(rest)-len("/jsonrpc")]
+	}
+	return &endpoint{
+		rpc:    base,
+		rest:   strings.TrimRight(rest, "/"),
+		apiKey: strings.TrimSpace(cfg.APIKey),
+		score:  1,
+	}
+}
+
+// currentScore applies idle recovery to the stored score. Callers hold mu.
+func (e *endpoint) currentScore(now time.Time) float64 {
+	if e.updatedAt.IsZero() {
+		return e.score
+	}
+	idle := now.Sub(e.updatedAt)
+	return 1 - (1-e.score)*math.Pow(0.5, float64(idle)/float64(endpointScoreRecovery))
+}
+
+func (e *endpoint) recordSuccess(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	e.score = e.currentScore(now)*(1-endpointScoreWeight) + endpointScoreWeight
+	e.updatedAt = now
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(float64(e.latency)*(1-endpointScoreWeight) + float64(latency)*endpointScoreWeight)
+	}
+	e.failures = 0
+	e.downUntil = time.Time{}
+}
+
+func (e *endpoint) recordFailure(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	e.score = e.currentScore(now) * (1 - endpointScoreWeight)
+	e.updatedAt = now
+	e.failures++
+	cooldown := endpointCooldownBase << min(e.failures-1, 10)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > cooldown {
+		cooldown = statusErr.RetryAfter
+	}
+	e.downUntil = now.Add(min(cooldown, endpointCooldownMax))
+	e.lastErr = err.Error()
+}
+
+// EndpointStatus is a snapshot of one provider's health.
+type EndpointStatus struct {
+	URL       string     `json:"url"`
+	Score     float64    `json:"score"`
+	LatencyMs int64      `json:"latency_ms"`
+	Failures  int        `json:"consecutive_failures"`
+	DownUntil *time.Time `json:"down_until,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (e *endpoint) status(now time.Time) EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := EndpointStatus{
+		URL:       e.rpc,
+		Score:     math.Round(e.currentScore(now)*1000) / 1000,
+		LatencyMs: e.latency.Milliseconds(),
+		Failures:  e.failures,
+		LastError: e.lastErr,
+	}
+	if now.Before(e.downUntil) {
+		until := e.downUntil
+		st.DownUntil = &until
+	}
+	return st
+}
+
+// Endpoints reports the health of every configured provider in configured
+// order.
+func (c *Client) Endpoints() []EndpointStatus {
+	now := time.Now()
+	out := make([]EndpointStatus, 0, len(c.endpoints))
+	for _, ep := range c.endpoints {
+		out = append(out, ep.status(now))
+	}
+	return out
+}
+
+// rank orders endpoints for one request: prefer first when it is not cooling
+// down, then healthy endpoints in configured order, then degraded ones by
+// score, and endpoints in cooldown last (soonest back first) so a request
+// is still attempted when every provider is struggling.
+func (c *Client) rank(prefer *endpoint) []*endpoint {
+	type candidate struct {
+		ep        *endpoint
+		idx       int
+		score     float64
+		downUntil time.Time
+	}
+	now := time.Now()
+	cands := make([]candidate, len(c.endpoints))
+	for i, ep := range c.endpoints {
+		ep.mu.Lock()
+		cands[i] = candidate{ep: ep, idx: i, score: ep.currentScore(now), downUntil: ep.downUntil}
+		ep.mu.Unlock()
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		a, b := cands[i], cands[j]
+		aDown, bDown := now.Before(a.downUntil), now.Before(b.downUntil)
+		if aDown != bDown {
+			return bDown
+		}
+		if aDown {
+			return a.downUntil.Before(b.downUntil)
+		}
+		if (a.ep == prefer) != (b.ep == prefer) {
+			return a.ep == prefer
+		}
+		aOK, bOK := a.score >= endpointHealthy, b.score >= endpointHealthy
+		if aOK != bOK {
+			return aOK
+		}
+		if !aOK && a.score != b.score {
+			return a.score > b.score
+		}
+		return a.idx < b.idx
+	})
+	out := make([]*endpoint, len(cands))
+	for i, cand := range cands {
+		out[i] = cand.ep
+	}
+	return out
+}
+
+// StatusError is an HTTP error answer from a provider.
+type StatusError struct {
+	Method     string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ton request %s failed: status %d body %s", e.Method, e.StatusCode, e.Body)
+}
+
+// retryable reports whether another provider might answer where this one
+// failed: transport errors, 5xx and rate limiting. Other HTTP errors are
+// answers about the request itself.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// roundTrip sends the request built by build to the best-ranked endpoint and
+// fails over to the next on retryable errors; every attempt waits for the
+// rate limiter at priority p. It returns the response body and the endpoint
+// that produced it.
+func (c *Client) roundTrip(ctx context.Context, method string, p priority, prefer *endpoint, build func(ep *endpoint) (*http.Request, error)) ([]byte, *endpoint, error) {
+	if len(c.endpoints) == 0 {
+		return nil, nil, errors.New("ton endpoint not configured")
+	}
+	var lastErr error
+	for _, ep := range c.rank(prefer) {
+		if err := c.limiter.wait(ctx, p); err != nil {
+			return nil, nil, err
+		}
+		req, err := build(ep)
+		if err != nil {
+			return nil, nil, err
+		}
+		start := time.Now()
+		body, err := c.send(req, method)
+		if err == nil {
+			ep.recordSuccess(time.Since(start))
+			return body, ep, nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider.
+			return nil, nil, err
+		}
+		if !retryable(err) {
+			ep.recordSuccess(time.Since(start))
+			return nil, nil, err
+		}
+		ep.recordFailure(err)
+		lastErr = err
+	}
+	return nil, nil, lastErr
+}
+
+func (c *Client) send(req *http.Request, method string) ([]byte, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &StatusError{
+			Method:     method,
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+}
+
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// pin routes later requests about message hash to ep until the message can
+// no longer land: a provider that accepted a broadcast sees the resulting
+// transaction first, and re-broadcasts go to the same place.
+func (c *Client) pin(hash string, ep *endpoint) {
+	if hash == "" || ep == nil || len(c.endpoints) < 2 {
+		return
+	}
+	now := time.Now()
+	c.pinsMu.Lock()
+	defer c.pinsMu.Unlock()
+	for h, p := range c.pins {
+		if now.After(p.until) {
+			delete(c.pins, h)
+		}
+	}
+	c.pins[strings.ToLower(hash)] = pinnedEndpoint{ep: ep, until: now.Add(broadcastStickyWindow)}
+}
+
+// pinned returns the endpoint pinned for message hash, or nil.
+func (c *Client) pinned(hash string) *endpoint {
+	c.pinsMu.Lock()
+	defer c.pinsMu.Unlock()
+	p, ok := c.pins[strings.ToLower(strings.TrimSpace(hash))]
+	if !ok || time.Now().After(p.until) {
+		return nil
+	}
+	return p.ep
+}
+
+type pinnedEndpoint struct {
+	ep    *endpoint
+	until time.Time
+}
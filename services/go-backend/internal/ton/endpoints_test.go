@@ -0,0 +1,179 @@
+package ton
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	tests := []struct {
+		list string
+		want []Endpoint
+	}{
+		{"", nil},
+		{" , ", nil},
+		{
+			"https://a/jsonRPC",
+			[]Endpoint{{URL: "https://a/jsonRPC", APIKey: "shared"}},
+		},
+		{
+			"https://a/jsonRPC|keyA, https://b/jsonRPC ,https://c/jsonRPC|",
+			[]Endpoint{
+				{URL: "https://a/jsonRPC", APIKey: "keyA"},
+				{URL: "https://b/jsonRPC", APIKey: "shared"},
+				{URL: "https://c/jsonRPC", APIKey: ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseEndpoints(tt.list, "shared"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseEndpoints(%q) = %+v, want %+v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestNewEndpointRestURL(t *testing.T) {
+	ep := newEndpoint(Endpoint{URL: " https://toncenter.com/api/v2/jsonRPC/ "})
+	if ep.rpc != "https://toncenter.com/api/v2/jsonRPC" {
+		t.Errorf("rpc = %q", ep.rpc)
+	}
+	if ep.rest != "https://toncenter.com/api/v2" {
+		t.Errorf("rest = %q", ep.rest)
+	}
+}
+
+func testClient(n int) *Client {
+	eps := make([]Endpoint, n)
+	for i := range eps {
+		eps[i] = Endpoint{URL: "https://rpc" + string(rune('a'+i)) + "/jsonRPC"}
+	}
+	return NewClient(Config{Endpoints: eps})
+}
+
+func TestRankOrder(t *testing.T) {
+	c := testClient(4)
+	a, b, cc, d := c.endpoints[0], c.endpoints[1], c.endpoints[2], c.endpoints[3]
+	now := time.Now()
+
+	if got := c.rank(nil); !reflect.DeepEqual(got, []*endpoint{a, b, cc, d}) {
+		t.Fatalf("healthy endpoints should keep configured order")
+	}
+	if got := c.rank(cc); !reflect.DeepEqual(got, []*endpoint{cc, a, b, d}) {
+		t.Fatalf("preferred endpoint should come first")
+	}
+
+	// a is degraded, b more so; d cools down; cc stays healthy.
+	a.score, a.updatedAt = 0.5, now
+	b.score, b.updatedAt = 0.3, now
+	d.downUntil = now.Add(time.Minute)
+	if got := c.rank(nil); !reflect.DeepEqual(got, []*endpoint{cc, a, b, d}) {
+		t.Errorf("rank = %v, want healthy, degraded by score, cooling last", urls(got))
+	}
+	// A cooling endpoint is not preferred over live ones.
+	if got := c.rank(d); got[len(got)-1] != d {
+		t.Errorf("rank(prefer cooling) = %v, want it last", urls(got))
+	}
+	// Cooling endpoints are ordered by when they come back.
+	cc.downUntil = now.Add(2 * time.Minute)
+	if got := c.rank(nil); !reflect.DeepEqual(got, []*endpoint{a, b, d, cc}) {
+		t.Errorf("rank = %v, want soonest-back cooling endpoint first", urls(got))
+	}
+}
+
+func urls(eps []*endpoint) []string {
+	out := make([]string, len(eps))
+	for i, ep := range eps {
+		out[i] = ep.rpc
+	}
+	return out
+}
+
+func TestEndpointScoreRecovers(t *testing.T) {
+	ep := newEndpoint(Endpoint{URL: "https://a"})
+	ep.score = 0.2
+	ep.updatedAt = time.Now().Add(-endpointScoreRecovery)
+	if got := ep.currentScore(time.Now()); got < 0.59 || got > 0.61 {
+		t.Errorf("score after one half-life = %v, want ~0.6", got)
+	}
+}
+
+func TestEndpointCooldown(t *testing.T) {
+	ep := newEndpoint(Endpoint{URL: "https://a"})
+	cooldown := func() time.Duration { return time.Until(ep.downUntil).Round(time.Second) }
+
+	ep.recordFailure(errors.New("dial tcp: refused"))
+	if got := cooldown(); got != endpointCooldownBase {
+		t.Errorf("first cooldown = %v, want %v", got, endpointCooldownBase)
+	}
+	ep.recordFailure(errors.New("dial tcp: refused"))
+	if got := cooldown(); got != 2*endpointCooldownBase {
+		t.Errorf("second cooldown = %v, want %v", got, 2*endpointCooldownBase)
+	}
+	ep.recordFailure(&StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second})
+	if got := cooldown(); got != 30*time.Second {
+		t.Errorf("cooldown with Retry-After = %v, want 30s", got)
+	}
+	for range 20 {
+		ep.recordFailure(errors.New("dial tcp: refused"))
+	}
+	if got := cooldown(); got != endpointCooldownMax {
+		t.Errorf("cooldown after many failures = %v, want cap %v", got, endpointCooldownMax)
+	}
+	ep.recordSuccess(time.Millisecond)
+	if ep.failures != 0 || !ep.downUntil.IsZero() {
+		t.Errorf("success should clear the cooldown, got failures=%d downUntil=%v", ep.failures, ep.downUntil)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset"), true},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{&StatusError{StatusCode: http.StatusBadGateway}, true},
+		{&StatusError{StatusCode: http.StatusBadRequest}, false},
+		{&StatusError{StatusCode: http.StatusUnauthorized}, false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRoundTripFailsOver(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer up.Close()
+
+	c := NewClient(Config{Endpoints: []Endpoint{{URL: down.URL}, {URL: up.URL}}})
+	build := func(ep *endpoint) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, ep.rpc, nil)
+	}
+	body, served, err := c.roundTrip(context.Background(), "test", priorityRead, nil, build)
+	if err != nil {
+		t.Fatalf("roundTrip: %v", err)
+	}
+	if string(body) != "ok" || served != c.endpoints[1] {
+		t.Fatalf("roundTrip = %q from %v, want ok from the second endpoint", body, served.rpc)
+	}
+	if got := time.Until(c.endpoints[0].downUntil).Round(time.Second); got != 10*time.Second {
+		t.Errorf("rate-limited endpoint cooldown = %v, want Retry-After 10s", got)
+	}
+	if got := c.rank(nil); got[0] != c.endpoints[1] {
+		t.Errorf("rank after failure = %v, want the live endpoint first", urls(got))
+	}
+}
@@ -0,0 +1,181 @@
+package ton
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// StackEntry is a single TVM stack value passed to or returned from a get-method.
+type StackEntry struct {
+	Num  *big.Int
+	Cell *cell.Cell
+}
+
+// NumArg wraps an integer get-method argument.
+func NumArg(n *big.Int) StackEntry {
+	return StackEntry{Num: n}
+}
+
+// SliceArg wraps a slice get-method argument.
+func SliceArg(c *cell.Cell) StackEntry {
+	return StackEntry{Cell: c}
+}
+
+// AddressArg wraps an address as a slice argument.
+func AddressArg(addr *address.Address) StackEntry {
+	return SliceArg(cell.BeginCell().MustStoreAddr(addr).EndCell())
+}
+
+// GetMethodResult holds the decoded output of a get-method call.
+type GetMethodResult struct {
+	ExitCode int
+	Stack    []StackEntry
+}
+
+// Int returns the integer at stack position i.
+func (r *GetMethodResult) Int(i int) (*big.Int, error) {
+	if i < 0 || i >= len(r.Stack) || r.Stack[i].Num == nil {
+		return nil, fmt.Errorf("stack entry %d is not a number", i)
+	}
+	return r.Stack[i].Num, nil
+}
+
+// Cell returns the cell at stack position i.
+func (r *GetMethodResult) Cell(i int) (*cell.Cell, error) {
+	if i < 0 || i >= len(r.Stack) || r.Stack[i].Cell == nil {
+		return nil, fmt.Errorf("stack entry %d is not a cell", i)
+	}
+	return r.Stack[i].Cell, nil
+}
+
+// Address decodes the slice at stack position i as an address.
+func (r *GetMethodResult) Address(i int) (*address.Address, error) {
+	c, err := r.Cell(i)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := c.BeginParse().LoadAddr()
+	if err != nil {
+		return nil, fmt.Errorf("stack entry %d: %w", i, err)
+	}
+	return addr, nil
+}
+
+// ErrGetMethodFailed is returned when a get-method exits with a non-zero code.
+var ErrGetMethodFailed = errors.New("ton client: get-method failed")
+
+// RunGetMethod executes a contract get-method through Toncenter runGetMethod.
+func (c *Client) RunGetMethod(ctx context.Context, addr, method string, args ...StackEntry) (*GetMethodResult, error) {
+	stack := make([][2]any, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case arg.Num != nil:
+			stack = append(stack, [2]any{"num", "0x" + arg.Num.Text(16)})
+		case arg.Cell != nil:
+			stack = append(stack, [2]any{"tvm.Slice", base64.StdEncoding.EncodeToString(arg.Cell.ToBOC())})
+		default:
+			return nil, fmt.Errorf("empty stack argument for %s", method)
+		}
+	}
+	payload := map[string]any{
+		"address": addr,
+		"method":  method,
+		"stack":   stack,
+	}
+	var resp tonRunGetMethodResponse
+	if err := c.post(ctx, "runGetMethod", payload, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Ok {
+		return nil, fmt.Errorf("ton get-method %s error: %s", method, resp.Error)
+	}
+	if resp.Result.ExitCode != 0 && resp.Result.ExitCode != 1 {
+		return nil, fmt.Errorf("%w: %s exit code %d", ErrGetMethodFailed, method, resp.Result.ExitCode)
+	}
+	out := &GetMethodResult{ExitCode: resp.Result.ExitCode}
+	for i, raw := range resp.Result.Stack {
+		entry, err := decodeStackEntry(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s stack[%d]: %w", method, i, err)
+		}
+		out.Stack = append(out.Stack, entry)
+	}
+	return out, nil
+}
+
+func decodeStackEntry(raw []json.RawMessage) (StackEntry, error) {
+	if len(raw) != 2 {
+		return StackEntry{}, fmt.Errorf("unexpected stack tuple size %d", len(raw))
+	}
+	var kind string
+	if err := json.Unmarshal(raw[0], &kind); err != nil {
+		return StackEntry{}, err
+	}
+	switch kind {
+	case "num":
+		var s string
+		if err := json.Unmarshal(raw[1], &s); err != nil {
+			return StackEntry{}, err
+		}
+		n, ok := parseStackNum(s)
+		if !ok {
+			return StackEntry{}, fmt.Errorf("bad number %q", s)
+		}
+		return StackEntry{Num: n}, nil
+	case "cell", "slice", "tvm.Cell", "tvm.Slice":
+		var obj struct {
+			Bytes string `json:"bytes"`
+		}
+		if err := json.Unmarshal(raw[1], &obj); err != nil || obj.Bytes == "" {
+			if err := json.Unmarshal(raw[1], &obj.Bytes); err != nil {
+				return StackEntry{}, fmt.Errorf("bad %s value", kind)
+			}
+		}
+		data, err := base64.StdEncoding.DecodeString(obj.Bytes)
+		if err != nil {
+			return StackEntry{}, err
+		}
+		root, err := cell.FromBOC(data)
+		if err != nil {
+			return StackEntry{}, err
+		}
+		return StackEntry{Cell: root}, nil
+	default:
+		// Tuples/lists are not used by the contracts we talk to; keep the slot so indexes stay stable.
+		return StackEntry{}, nil
+	}
+}
+
+func parseStackNum(s string) (*big.Int, bool) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	n := new(big.Int)
+	var ok bool
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		_, ok = n.SetString(s[2:], 16)
+	} else {
+		_, ok = n.SetString(s, 10)
+	}
+	if ok && neg {
+		n.Neg(n)
+	}
+	return n, ok
+}
+
+type tonRunGetMethodResponse struct {
+	Ok     bool   `json:"ok"`
+	Error  string `json:"error"`
+	Result struct {
+		ExitCode int                 `json:"exit_code"`
+		Stack    [][]json.RawMessage `json:"stack"`
+	} `json:"result"`
+}
@@ -0,0 +1,299 @@
+package ton
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// OpJettonTransferNotification is the TEP-74 opcode a jetton wallet sends to
+// its owner on receiving jettons.
+const OpJettonTransferNotification = 0x7362d09c
+
+const (
+	// DefaultHistoryPage is the page size used when none is requested.
+	DefaultHistoryPage = 20
+	// MaxHistoryPage bounds one page of wallet history.
+	MaxHistoryPage = 50
+)
+
+// ErrInvalidCursor means a history cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("ton client: invalid history cursor")
+
+// TxCursor is the (lt, hash) of the last transaction of a history page; the
+// next page starts right after it.
+type TxCursor struct {
+	LT   string
+	Hash string // base64, as returned by getTransactions
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c TxCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.LT + ":" + c.Hash))
+}
+
+// ParseTxCursor decodes a token produced by TxCursor.String.
+func ParseTxCursor(token string) (*TxCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	lt, hash, ok := strings.Cut(string(raw), ":")
+	if !ok || hash == "" {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := strconv.ParseUint(lt, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := base64.StdEncoding.DecodeString(hash); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &TxCursor{LT: lt, Hash: hash}, nil
+}
+
+// WalletTransaction is one decoded wallet transaction.
+type WalletTransaction struct {
+	Hash    string    `json:"tx_hash"` // hex transaction hash
+	LT      uint64    `json:"lt"`
+	At      time.Time `json:"at"`
+	Fee     string    `json:"fee"` // TON
+	Success bool      `json:"success"`
+	// In is nil for transactions not triggered by a message (tick-tock).
+	In  *TxMessage  `json:"in,omitempty"`
+	Out []TxMessage `json:"out"`
+}
+
+// TxMessage is a message received or sent by a wallet transaction.
+type TxMessage struct {
+	// External is set for the signed external message that made the wallet
+	// send; it carries no value and no sender.
+	External bool   `json:"external,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Value    string `json:"value"` // TON
+	Bounced  bool   `json:"bounced,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+	// Op is the hex opcode of bodies that are neither comments nor jetton
+	// transfers.
+	Op     string        `json:"op,omitempty"`
+	Jetton *JettonNotice `json:"jetton,omitempty"`
+}
+
+// JettonNotice describes a jetton movement carried by a message body: an
+// outgoing transfer request or an incoming transfer_notification.
+type JettonNotice struct {
+	Kind string `json:"kind"` // "transfer" or "notification"
+	// Wallet is the jetton wallet involved: the destination of a transfer,
+	// the sender of a notification.
+	Wallet string `json:"jetton_wallet"`
+	Amount string `json:"amount"` // raw jetton units
+	// Counterparty is the recipient of a transfer or the original sender of
+	// a notification.
+	Counterparty string `json:"counterparty,omitempty"`
+	Comment      string `json:"comment,omitempty"`
+}
+
+// GetTransactions returns up to limit transactions of wallet, newest first,
+// starting after cursor (from the latest when nil). The returned cursor
+// points at the next page and is nil once the history is exhausted.
+func (c *Client) GetTransactions(ctx context.Context, wallet *address.Address, cursor *TxCursor, limit int) ([]WalletTransaction, *TxCursor, error) {
+	if limit <= 0 {
+		limit = DefaultHistoryPage
+	}
+	if limit > MaxHistoryPage {
+		limit = MaxHistoryPage
+	}
+	// One extra row tells whether another page exists; with a cursor the
+	// cursor transaction itself comes back first as well.
+	fetch := limit + 1
+	params := url.Values{
+		"address":  {wallet.String()},
+		"archival": {"true"},
+	}
+	if cursor != nil {
+		fetch++
+		params.Set("lt", cursor.LT)
+		params.Set("hash", cursor.Hash)
+	}
+	params.Set("limit", strconv.Itoa(fetch))
+	var resp tonTransactionsResponse
+	if err := c.call(ctx, "getTransactions", params, &resp); err != nil {
+		return nil, nil, err
+	}
+	if !resp.Ok {
+		return nil, nil, fmt.Errorf("ton transactions error: %s", resp.Error)
+	}
+	txs := resp.Result
+	if cursor != nil && len(txs) > 0 && txs[0].TransactionID.LT == cursor.LT && txs[0].TransactionID.Hash == cursor.Hash {
+		txs = txs[1:]
+	}
+	more := len(txs) > limit
+	if more {
+		txs = txs[:limit]
+	}
+	out := make([]WalletTransaction, 0, len(txs))
+	for _, raw := range txs {
+		entry, err := decodeWalletTransaction(raw.Data)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, *entry)
+	}
+	var next *TxCursor
+	if more {
+		last := txs[len(txs)-1]
+		next = &TxCursor{LT: last.TransactionID.LT, Hash: last.TransactionID.Hash}
+	}
+	return out, next, nil
+}
+
+func decodeWalletTransaction(data string) (*WalletTransaction, error) {
+	tx, root, err := parseTransaction(data)
+	if err != nil {
+		return nil, err
+	}
+	res := txResult(tx, root)
+	entry := &WalletTransaction{
+		Hash:    res.Hash,
+		LT:      tx.LT,
+		At:      time.Unix(int64(tx.Now), 0).UTC(),
+		Fee:     formatBigTon(tx.TotalFees.Coins.Nano()),
+		Success: res.Success(),
+		Out:     []TxMessage{},
+	}
+	if tx.IO.In != nil {
+		in := describeMessage(tx.IO.In)
+		entry.In = &in
+	}
+	if tx.IO.Out != nil {
+		msgs, err := tx.IO.Out.ToSlice()
+		if err != nil {
+			return nil, fmt.Errorf("parse transaction: %w", err)
+		}
+		for i := range msgs {
+			if msgs[i].MsgType == tlb.MsgTypeInternal {
+				entry.Out = append(entry.Out, describeMessage(&msgs[i]))
+			}
+		}
+	}
+	return entry, nil
+}
+
+func describeMessage(msg *tlb.Message) TxMessage {
+	if msg.MsgType != tlb.MsgTypeInternal {
+		return TxMessage{External: true, Value: "0"}
+	}
+	in := msg.AsInternal()
+	m := TxMessage{
+		From:    messageAddr(in.SrcAddr),
+		To:      messageAddr(in.DstAddr),
+		Value:   formatBigTon(in.Amount.Nano()),
+		Bounced: in.Bounced,
+	}
+	if in.Body == nil {
+		return m
+	}
+	body := in.Body.BeginParse()
+	op, err := body.LoadUInt(32)
+	if err != nil {
+		return m
+	}
+	switch op {
+	case 0:
+		m.Comment, _ = body.LoadStringSnake()
+	case OpJettonTransfer:
+		m.Jetton = decodeJettonTransfer(body, m.To)
+	case OpJettonTransferNotification:
+		m.Jetton = decodeJettonNotification(body, m.From)
+	}
+	if m.Jetton == nil && op != 0 {
+		m.Op = fmt.Sprintf("0x%08x", op)
+	}
+	return m
+}
+
+// decodeJettonTransfer reads a TEP-74 transfer body after the opcode.
+func decodeJettonTransfer(body *cell.Slice, jettonWallet string) *JettonNotice {
+	if _, err := body.LoadUInt(64); err != nil {
+		return nil
+	}
+	amount, err := body.LoadBigCoins()
+	if err != nil {
+		return nil
+	}
+	dest, err := body.LoadAddr()
+	if err != nil {
+		return nil
+	}
+	notice := &JettonNotice{Kind: "transfer", Wallet: jettonWallet, Amount: amount.String(), Counterparty: messageAddr(dest)}
+	if _, err := body.LoadAddr(); err != nil { // response destination
+		return notice
+	}
+	if _, err := body.LoadMaybeRef(); err != nil { // custom payload
+		return notice
+	}
+	if _, err := body.LoadBigCoins(); err != nil { // forward TON
+		return notice
+	}
+	notice.Comment = forwardComment(body)
+	return notice
+}
+
+// decodeJettonNotification reads a TEP-74 transfer_notification body after
+// the opcode.
+func decodeJettonNotification(body *cell.Slice, jettonWallet string) *JettonNotice {
+	if _, err := body.LoadUInt(64); err != nil {
+		return nil
+	}
+	amount, err := body.LoadBigCoins()
+	if err != nil {
+		return nil
+	}
+	sender, err := body.LoadAddr()
+	if err != nil {
+		return nil
+	}
+	return &JettonNotice{
+		Kind:         "notification",
+		Wallet:       jettonWallet,
+		Amount:       amount.String(),
+		Counterparty: messageAddr(sender),
+		Comment:      forwardComment(body),
+	}
+}
+
+// forwardComment extracts a text comment from an Either Cell ^Cell forward
+// payload; other payloads yield "".
+func forwardComment(body *cell.Slice) string {
+	inRef, err := body.LoadBoolBit()
+	if err != nil {
+		return ""
+	}
+	payload := body
+	if inRef {
+		if payload, err = body.LoadRef(); err != nil {
+			return ""
+		}
+	}
+	if op, err := payload.LoadUInt(32); err != nil || op != 0 {
+		return ""
+	}
+	comment, _ := payload.LoadStringSnake()
+	return comment
+}
+
+func messageAddr(addr *address.Address) string {
+	if addr == nil || addr.Type() != address.StdAddress {
+		return ""
+	}
+	return addr.String()
+}
@@ -0,0 +1,41 @@
+package ton
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestTxCursorRoundTrip(t *testing.T) {
+	cursors := []TxCursor{
+		{LT: "47212345000001", Hash: "q2x3Vd+/Zb1kU4n0cE1p3QdXnC0tS0Q9R7W6N8m2yZ4="},
+		{LT: "0", Hash: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+	}
+	for _, want := range cursors {
+		token := want.String()
+		got, err := ParseTxCursor(token)
+		if err != nil {
+			t.Fatalf("ParseTxCursor(%q): %v", token, err)
+		}
+		if *got != want {
+			t.Errorf("ParseTxCursor(%q) = %+v, want %+v", token, *got, want)
+		}
+	}
+}
+
+func TestParseTxCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tokens := map[string]string{
+		"not base64":   "%%%",
+		"no separator": encode("123"),
+		"empty hash":   encode("123:"),
+		"bad lt":       encode("-1:AAAA"),
+		"lt not int":   encode("abc:AAAA"),
+		"bad hash":     encode("123:not*base64"),
+	}
+	for name, token := range tokens {
+		if _, err := ParseTxCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: ParseTxCursor(%q) error = %v, want ErrInvalidCursor", name, token, err)
+		}
+	}
+}
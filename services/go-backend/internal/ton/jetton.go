@@ -0,0 +1,429 @@
+package ton
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// OpJettonTransfer is the TEP-74 jetton transfer opcode.
+const OpJettonTransfer = 0x0f8a7ea5
+
+// JettonTransferParams describes a TEP-74 transfer body.
+type JettonTransferParams struct {
+	QueryID         uint64
+	Amount          *big.Int
+	Destination     *address.Address
+	ResponseAddress *address.Address
+	ForwardTon      *big.Int
+	ForwardPayload  *cell.Cell
+}
+
+// BuildJettonTransferBody encodes a jetton transfer message body.
+func BuildJettonTransferBody(p JettonTransferParams) (*cell.Cell, error) {
+	if p.Amount == nil || p.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid jetton amount")
+	}
+	if p.Destination == nil {
+		return nil, ErrInvalidDestination
+	}
+	forwardTon := p.ForwardTon
+	if forwardTon == nil {
+		forwardTon = big.NewInt(0)
+	}
+	b := cell.BeginCell().
+		MustStoreUInt(OpJettonTransfer, 32).
+		MustStoreUInt(p.QueryID, 64).
+		MustStoreBigCoins(p.Amount).
+		MustStoreAddr(p.Destination).
+		MustStoreAddr(p.ResponseAddress).
+		MustStoreBoolBit(false). // no custom payload
+		MustStoreBigCoins(forwardTon)
+	if p.ForwardPayload != nil {
+		b.MustStoreBoolBit(true).MustStoreRef(p.ForwardPayload)
+	} else {
+		b.MustStoreBoolBit(false)
+	}
+	return b.EndCell(), nil
+}
+
+// GetJettonWalletAddress resolves owner's jetton wallet for the given jetton master.
+func (c *Client) GetJettonWalletAddress(ctx context.Context, master, owner *address.Address) (*address.Address, error) {
+	res, err := c.RunGetMethod(ctx, master.String(), "get_wallet_address", AddressArg(owner))
+	if err != nil {
+		return nil, err
+	}
+	return res.Address(0)
+}
+
+// GetJettonWalletBalance reads the balance stored in a jetton wallet.
+// Undeployed wallets report a zero balance.
+func (c *Client) GetJettonWalletBalance(ctx context.Context, jettonWallet *address.Address) (*big.Int, error) {
+	res, err := c.RunGetMethod(ctx, jettonWallet.String(), "get_wallet_data")
+	if err != nil {
+		if errors.Is(err, ErrGetMethodFailed) {
+			return big.NewInt(0), nil
+		}
+		return nil, err
+	}
+	return res.Int(0)
+}
+
+// JettonBalance is an owner's holding of one jetton.
+type JettonBalance struct {
+	Master   string `json:"jetton_master"`
+	Wallet   string `json:"jetton_wallet,omitempty"`
+	Balance  string `json:"balance"` // raw jetton units
+	Amount   string `json:"amount"`  // balance scaled by decimals
+	Decimals int    `json:"decimals"`
+	// Error is set when this jetton could not be read; the other fields
+	// except Master are then empty.
+	Error string `json:"error,omitempty"`
+}
+
+// jettonBalanceWorkers bounds concurrent get-method calls per listing.
+const jettonBalanceWorkers = 4
+
+// GetJettonBalances resolves owner's jetton wallet for each master via
+// get_wallet_address and reads its balance via get_wallet_data. A jetton that
+// cannot be read is reported with Error instead of failing the whole list;
+// the result keeps the order of masters.
+func (c *Client) GetJettonBalances(ctx context.Context, owner *address.Address, masters []*address.Address) ([]JettonBalance, error) {
+	out := make([]JettonBalance, len(masters))
+	sem := make(chan struct{}, jettonBalanceWorkers)
+	var wg sync.WaitGroup
+	for i, master := range masters {
+		wg.Add(1)
+		go func(i int, master *address.Address) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				out[i] = JettonBalance{Master: master.String(), Error: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-sem }()
+			bal, err := c.GetJettonBalance(ctx, owner, master)
+			if err != nil {
+				out[i] = JettonBalance{Master: master.String(), Error: err.Error()}
+				return
+			}
+			out[i] = *bal
+		}(i, master)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetJettonBalance reads owner's balance of one jetton.
+func (c *Client) GetJettonBalance(ctx context.Context, owner, master *address.Address) (*JettonBalance, error) {
+	jettonWallet, err := c.GetJettonWalletAddress(ctx, master, owner)
+	if err != nil {
+		return nil, fmt.Errorf("jetton wallet: %w", err)
+	}
+	raw, err := c.GetJettonWalletBalance(ctx, jettonWallet)
+	if err != nil {
+		return nil, fmt.Errorf("jetton balance: %w", err)
+	}
+	decimals, err := c.jettonDecimals(ctx, master)
+	if err != nil {
+		return nil, fmt.Errorf("jetton decimals: %w", err)
+	}
+	return &JettonBalance{
+		Master:   master.String(),
+		Wallet:   jettonWallet.String(),
+		Balance:  raw.String(),
+		Amount:   formatUnits(raw, decimals),
+		Decimals: decimals,
+	}, nil
+}
+
+// jettonDecimals is GetJettonDecimals cached per master; decimals never
+// change once a jetton is deployed.
+func (c *Client) jettonDecimals(ctx context.Context, master *address.Address) (int, error) {
+	key := master.String()
+	c.decimalsMu.Lock()
+	cached, ok := c.decimals[key]
+	c.decimalsMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+	decimals, err := c.GetJettonDecimals(ctx, master)
+	if err != nil {
+		return 0, err
+	}
+	c.decimalsMu.Lock()
+	c.decimals[key] = decimals
+	c.decimalsMu.Unlock()
+	return decimals, nil
+}
+
+// DefaultJettonDecimals is assumed when metadata does not specify decimals.
+const DefaultJettonDecimals = 9
+
+// GetJettonDecimals resolves the decimals declared in TEP-64 metadata of a
+// jetton master, following off-chain URIs when needed.
+func (c *Client) GetJettonDecimals(ctx context.Context, master *address.Address) (int, error) {
+	res, err := c.RunGetMethod(ctx, master.String(), "get_jetton_data")
+	if err != nil {
+		return 0, err
+	}
+	content, err := res.Cell(3)
+	if err != nil {
+		return 0, err
+	}
+	slice := content.BeginParse()
+	layout, err := slice.LoadUInt(8)
+	if err != nil {
+		return DefaultJettonDecimals, nil
+	}
+	switch layout {
+	case 0x00:
+		dict, err := slice.LoadDict(256)
+		if err != nil || dict == nil {
+			return DefaultJettonDecimals, nil
+		}
+		value, err := dict.LoadValueByIntKey(metadataKey("decimals"))
+		if err != nil {
+			return DefaultJettonDecimals, nil
+		}
+		data, err := value.LoadRef()
+		if err != nil {
+			return DefaultJettonDecimals, nil
+		}
+		if _, err := data.LoadUInt(8); err != nil {
+			return DefaultJettonDecimals, nil
+		}
+		str, err := data.LoadStringSnake()
+		if err != nil {
+			return DefaultJettonDecimals, nil
+		}
+		return parseDecimals(str), nil
+	case 0x01:
+		uri, err := slice.LoadStringSnake()
+		if err != nil {
+			return DefaultJettonDecimals, nil
+		}
+		meta, err := c.fetchJettonMetadata(ctx, uri)
+		if err != nil {
+			return 0, err
+		}
+		return parseDecimals(string(meta.Decimals)), nil
+	default:
+		return DefaultJettonDecimals, nil
+	}
+}
+
+type jettonMetadata struct {
+	Decimals json.RawMessage `json:"decimals"`
+}
+
+func (c *Client) fetchJettonMetadata(ctx context.Context, uri string) (*jettonMetadata, error) {
+	uri = strings.TrimSpace(uri)
+	if strings.HasPrefix(uri, "ipfs://") {
+		uri = "https://ipfs.io/ipfs/" + strings.TrimPrefix(uri, "ipfs://")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("jetton metadata %s: status %d", uri, resp.StatusCode)
+	}
+	var meta jettonMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("jetton metadata %s: %w", uri, err)
+	}
+	return &meta, nil
+}
+
+func metadataKey(name string) *big.Int {
+	sum := sha256.Sum256([]byte(name))
+	return new(big.Int).SetBytes(sum[:])
+}
+
+func parseDecimals(raw string) int {
+	v, err := strconv.Atoi(strings.Trim(strings.TrimSpace(raw), `"`))
+	if err != nil || v < 0 || v > 255 {
+		return DefaultJettonDecimals
+	}
+	return v
+}
+
+// JettonTransferRequest encapsulates jetton transfer parameters.
+type JettonTransferRequest struct {
+	Mnemonic string
+	// Version is the sender's wallet version (V4R2 when empty).
+	Version WalletVersion
+	// Jetton is the jetton master address.
+	Jetton string
+	To     string
+	// Amount is in whole jetton units, e.g. "12.5".
+	Amount string
+	// ForwardTon is attached to the transfer notification sent to To.
+	ForwardTon float64
+	Comment    string
+	// ResponseTo receives the excess TON; the sender when empty.
+	ResponseTo string
+}
+
+// ErrInvalidJettonAmount means the amount is not positive or has more
+// fractional digits than the jetton's decimals.
+var ErrInvalidJettonAmount = errors.New("ton client: invalid jetton amount")
+
+// ErrInvalidJetton means the jetton master address cannot be parsed.
+var ErrInvalidJetton = errors.New("ton client: invalid jetton address")
+
+// jettonTransferGas is the TON attached for the sender's and recipient's
+// jetton wallets on top of the forwarded amount; the excess is returned.
+var jettonTransferGas = big.NewInt(50_000_000) // 0.05 TON
+
+// TransferJetton sends a TEP-74 transfer to the sender's jetton wallet. It
+// returns ErrInvalidDestination for a bad recipient or response address and
+// ErrInsufficientBalance when either the jettons or the TON for gas and the
+// forward amount are short.
+func (c *Client) TransferJetton(ctx context.Context, req JettonTransferRequest) (*SentMessage, error) {
+	if strings.TrimSpace(req.Mnemonic) == "" {
+		return nil, fmt.Errorf("mnemonic is required")
+	}
+	destAddr, err := address.ParseAddr(strings.TrimSpace(req.To))
+	if err != nil {
+		return nil, ErrInvalidDestination
+	}
+	master, err := address.ParseAddr(strings.TrimSpace(req.Jetton))
+	if err != nil {
+		return nil, ErrInvalidJetton
+	}
+	if req.ForwardTon < 0 {
+		return nil, fmt.Errorf("invalid forward amount")
+	}
+	contract, err := c.OpenWallet(req.Mnemonic, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	owner := contract.WalletAddress()
+	responseAddr := owner
+	if v := strings.TrimSpace(req.ResponseTo); v != "" {
+		if responseAddr, err = address.ParseAddr(v); err != nil {
+			return nil, ErrInvalidDestination
+		}
+	}
+	decimals, err := c.jettonDecimals(ctx, master)
+	if err != nil {
+		return nil, fmt.Errorf("jetton decimals: %w", err)
+	}
+	amount, err := parseUnits(req.Amount, decimals)
+	if err != nil {
+		return nil, err
+	}
+	jettonWallet, err := c.GetJettonWalletAddress(ctx, master, owner)
+	if err != nil {
+		return nil, fmt.Errorf("jetton wallet: %w", err)
+	}
+	jettons, err := c.GetJettonWalletBalance(ctx, jettonWallet)
+	if err != nil {
+		return nil, fmt.Errorf("jetton balance: %w", err)
+	}
+	if jettons.Cmp(amount) < 0 {
+		return nil, ErrInsufficientBalance
+	}
+	forwardTon := big.NewInt(0)
+	if req.ForwardTon > 0 {
+		coins, err := coinsFromFloat(req.ForwardTon)
+		if err != nil {
+			return nil, err
+		}
+		forwardTon = coins.Nano()
+	}
+	var payload *cell.Cell
+	if strings.TrimSpace(req.Comment) != "" {
+		if payload, err = wallet.CreateCommentCell(req.Comment); err != nil {
+			return nil, fmt.Errorf("build comment: %w", err)
+		}
+	}
+	body, err := BuildJettonTransferBody(JettonTransferParams{
+		QueryID:         uint64(time.Now().UnixNano()),
+		Amount:          amount,
+		Destination:     destAddr,
+		ResponseAddress: responseAddr,
+		ForwardTon:      forwardTon,
+		ForwardPayload:  payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fromAddr := owner.String()
+	walletInfo, err := c.seqnoInfo(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+	addrInfo, err := c.loadAddressInfo(ctx, fromAddr)
+	if err != nil {
+		return nil, fmt.Errorf("address info: %w", err)
+	}
+	balance, err := c.GetAccountBalance(ctx, fromAddr)
+	if err != nil {
+		return nil, fmt.Errorf("wallet balance: %w", err)
+	}
+	balanceNano := parseBigInt(balance.Nano)
+	if balanceNano == nil {
+		return nil, fmt.Errorf("invalid balance")
+	}
+	stateActive := addrInfo != nil && strings.EqualFold(addrInfo.State, "active")
+	attached := new(big.Int).Add(jettonTransferGas, forwardTon)
+	reserve := big.NewInt(20_000_000)
+	if stateActive {
+		reserve = big.NewInt(10_000_000)
+	}
+	if balanceNano.Cmp(new(big.Int).Add(attached, reserve)) < 0 {
+		return nil, ErrInsufficientBalance
+	}
+	msg := &wallet.Message{
+		Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+		InternalMessage: &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      true,
+			DstAddr:     jettonWallet,
+			Amount:      tlb.FromNanoTON(attached),
+			Body:        body,
+		},
+	}
+	return c.signAndBroadcast(ctx, contract, walletInfo, stateActive, []*wallet.Message{msg})
+}
+
+// parseUnits converts a decimal amount into raw units with the given
+// decimals. Amounts finer than one raw unit are rejected, not rounded.
+func parseUnits(amount string, decimals int) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrInvalidJettonAmount
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	if !r.IsInt() {
+		return nil, ErrInvalidJettonAmount
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
@@ -0,0 +1,131 @@
+package ton
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// priority orders requests waiting for the rate limiter.
+type priority int
+
+const (
+	priorityRead priority = iota
+	// priorityBroadcast jumps ahead of queued reads: a signed message loses
+	// validity while it waits.
+	priorityBroadcast
+	numPriorities
+)
+
+// limiter is a token bucket shared by all requests of a Client. Requests
+// that find the bucket empty queue per priority; a token always goes to the
+// oldest waiter of the highest non-empty priority.
+type limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	queues [numPriorities][]chan struct{}
+	timer  *time.Timer
+}
+
+// newLimiter returns nil (no limit) for a non-positive rate. burst defaults
+// to one second worth of tokens.
+func newLimiter(rate float64, burst int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a request of priority p may be sent or ctx is done.
+func (l *limiter) wait(ctx context.Context, p priority) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	l.refill(time.Now())
+	if l.tokens >= 1 && !l.queuedFrom(p) {
+		l.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	l.queues[p] = append(l.queues[p], ready)
+	l.schedule()
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.dequeue(p, ready) {
+			// Granted while giving up: pass the token on.
+			l.tokens++
+			l.dispatch()
+		}
+		return ctx.Err()
+	}
+}
+
+// queuedFrom reports whether requests of priority p or higher are waiting.
+func (l *limiter) queuedFrom(p priority) bool {
+	for q := p; q < numPriorities; q++ {
+		if len(l.queues[q]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *limiter) refill(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// dispatch hands available tokens to waiters. Callers hold mu.
+func (l *limiter) dispatch() {
+	l.refill(time.Now())
+	for p := numPriorities - 1; p >= 0; p-- {
+		for len(l.queues[p]) > 0 && l.tokens >= 1 {
+			l.tokens--
+			close(l.queues[p][0])
+			l.queues[p] = l.queues[p][1:]
+		}
+	}
+	l.schedule()
+}
+
+// schedule arms the timer for the next token while anyone waits. Callers
+// hold mu.
+func (l *limiter) schedule() {
+	if l.timer != nil || !l.queuedFrom(priorityRead) {
+		return
+	}
+	delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	l.timer = time.AfterFunc(max(delay, time.Millisecond), func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		l.dispatch()
+	})
+}
+
+// dequeue removes a waiter that gave up; false means it was already granted.
+func (l *limiter) dequeue(p priority, ready chan struct{}) bool {
+	for i, w := range l.queues[p] {
+		if w == ready {
+			l.queues[p] = append(l.queues[p][:i], l.queues[p][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
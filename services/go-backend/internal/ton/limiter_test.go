@@ -0,0 +1,99 @@
+package ton
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewLimiter(t *testing.T) {
+	if l := newLimiter(0, 5); l != nil {
+		t.Errorf("newLimiter(0) = %+v, want nil", l)
+	}
+	if err := (*limiter)(nil).wait(context.Background(), priorityRead); err != nil {
+		t.Errorf("nil limiter wait: %v", err)
+	}
+	if l := newLimiter(2.5, 0); l.burst != 3 {
+		t.Errorf("default burst = %v, want 3", l.burst)
+	}
+}
+
+// queued waits until n requests of priority p are queued.
+func queued(t *testing.T, l *limiter, p priority, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		l.mu.Lock()
+		got := len(l.queues[p])
+		l.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests of priority %d queued, want %d", got, p, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterBroadcastJumpsQueuedReads(t *testing.T) {
+	l := newLimiter(50, 1)
+	ctx := context.Background()
+	if err := l.wait(ctx, priorityRead); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	start := func(name string, p priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.wait(ctx, p); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}()
+	}
+	start("read1", priorityRead)
+	queued(t, l, priorityRead, 1)
+	start("read2", priorityRead)
+	queued(t, l, priorityRead, 2)
+	start("broadcast", priorityBroadcast)
+	wg.Wait()
+
+	want := []string{"broadcast", "read1", "read2"}
+	if len(order) != len(want) {
+		t.Fatalf("grant order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("grant order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestLimiterCancelledWaiter(t *testing.T) {
+	l := newLimiter(1, 1)
+	if err := l.wait(context.Background(), priorityRead); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, priorityRead); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait error = %v, want deadline exceeded", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.queuedFrom(priorityRead) {
+		t.Error("a cancelled waiter should leave the queue")
+	}
+}
@@ -0,0 +1,266 @@
+package ton
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// MessageTTL is the validity window signed into every external message.
+const MessageTTL = 3 * time.Minute
+
+const (
+	txPageSize     = 20
+	txMaxPages     = 5
+	confirmLatency = 30 * time.Second // block + indexer lag after valid_until
+)
+
+// SentMessage identifies a signed external message handed to the network.
+type SentMessage struct {
+	Wallet     *address.Address
+	Hash       string // hex hash of the external message cell
+	SentAt     time.Time
+	ValidUntil time.Time
+}
+
+// PreparedMessage is a signed external message that has not been broadcast yet.
+type PreparedMessage struct {
+	SentMessage
+	BOC string // base64 BOC for sendTransaction
+}
+
+// TxResult is the outcome of the wallet transaction that processed a message.
+type TxResult struct {
+	Hash             string `json:"tx_hash"` // hex transaction hash
+	LT               uint64 `json:"lt"`
+	ComputeExitCode  int32  `json:"exit_code"`
+	ActionResultCode int32  `json:"action_result_code"`
+	Aborted          bool   `json:"aborted"`
+
+	outBodies map[string]bool // hex hashes of the bodies of created messages
+}
+
+// Success reports whether compute and action phases both succeeded.
+func (r *TxResult) Success() bool {
+	return !r.Aborted && r.ComputeExitCode == 0 && r.ActionResultCode == 0
+}
+
+// Emitted reports whether the transaction created an outgoing message whose
+// body hashes to bodyHash (hex). Wallets sending with IgnoreErrors skip
+// messages they cannot pay for, so one transaction may carry only part of a
+// batch.
+func (r *TxResult) Emitted(bodyHash string) bool {
+	return r.outBodies[strings.ToLower(strings.TrimSpace(bodyHash))]
+}
+
+// BodyHash returns the hex hash of an outgoing message body, as matched by
+// TxResult.Emitted.
+func BodyHash(body *cell.Cell) string {
+	if body == nil {
+		body = cell.BeginCell().EndCell()
+	}
+	return hex.EncodeToString(body.Hash())
+}
+
+// Expired reports whether a message sent with validUntil can no longer land.
+func Expired(validUntil time.Time) bool {
+	return time.Now().After(validUntil.Add(confirmLatency))
+}
+
+// FindMessageTransaction scans the wallet's transactions newer than since for
+// the one that processed the external message msgHash. It returns nil when
+// the message has not been processed (yet).
+func (c *Client) FindMessageTransaction(ctx context.Context, wallet *address.Address, msgHash string, since time.Time) (*TxResult, error) {
+	want, err := hex.DecodeString(strings.TrimSpace(msgHash))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message hash: %w", err)
+	}
+	params := url.Values{
+		"address": {wallet.String()},
+		"limit":   {strconv.Itoa(txPageSize)},
+	}
+	// Ask the provider that took the broadcast: others may not have indexed
+	// the transaction yet.
+	prefer := c.pinned(msgHash)
+	for page := 0; page < txMaxPages; page++ {
+		var resp tonTransactionsResponse
+		if err := c.read(ctx, "getTransactions", params, &resp, readOpts{prefer: prefer}); err != nil {
+			return nil, err
+		}
+		if !resp.Ok {
+			return nil, fmt.Errorf("ton transactions error: %s", resp.Error)
+		}
+		txs := resp.Result
+		if page > 0 && len(txs) > 0 {
+			txs = txs[1:] // paging is inclusive of the cursor transaction
+		}
+		if len(txs) == 0 {
+			return nil, nil
+		}
+		for _, raw := range txs {
+			if raw.Utime > 0 && time.Unix(raw.Utime, 0).Before(since.Add(-time.Minute)) {
+				return nil, nil
+			}
+			res, inHash, err := decodeTransaction(raw.Data)
+			if err != nil {
+				return nil, err
+			}
+			if inHash != nil && string(inHash) == string(want) {
+				return res, nil
+			}
+		}
+		last := txs[len(txs)-1]
+		params.Set("lt", last.TransactionID.LT)
+		params.Set("hash", last.TransactionID.Hash)
+	}
+	return nil, nil
+}
+
+// InboundMessage is an internal message delivered to a wallet.
+type InboundMessage struct {
+	LT  uint64 // logical time of the receiving transaction
+	At  time.Time
+	Msg *tlb.InternalMessage
+}
+
+// ListInboundMessages returns internal messages the wallet received in
+// transactions after afterLT, oldest first. At most txMaxPages pages are read.
+func (c *Client) ListInboundMessages(ctx context.Context, wallet *address.Address, afterLT uint64) ([]InboundMessage, error) {
+	params := url.Values{
+		"address": {wallet.String()},
+		"limit":   {strconv.Itoa(txPageSize)},
+	}
+	var out []InboundMessage
+	for page := 0; page < txMaxPages; page++ {
+		var resp tonTransactionsResponse
+		if err := c.call(ctx, "getTransactions", params, &resp); err != nil {
+			return nil, err
+		}
+		if !resp.Ok {
+			return nil, fmt.Errorf("ton transactions error: %s", resp.Error)
+		}
+		txs := resp.Result
+		if page > 0 && len(txs) > 0 {
+			txs = txs[1:]
+		}
+		if len(txs) == 0 {
+			break
+		}
+		done := false
+		for _, raw := range txs {
+			tx, _, err := parseTransaction(raw.Data)
+			if err != nil {
+				return nil, err
+			}
+			if tx.LT <= afterLT {
+				done = true
+				break
+			}
+			if tx.IO.In == nil || tx.IO.In.MsgType != tlb.MsgTypeInternal {
+				continue
+			}
+			out = append(out, InboundMessage{LT: tx.LT, At: time.Unix(int64(tx.Now), 0), Msg: tx.IO.In.AsInternal()})
+		}
+		if done {
+			break
+		}
+		last := txs[len(txs)-1]
+		params.Set("lt", last.TransactionID.LT)
+		params.Set("hash", last.TransactionID.Hash)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// decodeTransaction parses a raw transaction BOC and returns its outcome plus
+// the hash of its inbound message (nil for transactions without one).
+func decodeTransaction(data string) (*TxResult, []byte, error) {
+	tx, root, err := parseTransaction(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := txResult(tx, root)
+	// The inbound message is the first (maybe) ref of the IO cell; hashing
+	// that cell directly avoids re-serializing the parsed message.
+	ioCell, err := root.PeekRef(0)
+	if err != nil {
+		return res, nil, nil
+	}
+	io := ioCell.BeginParse()
+	hasIn, err := io.LoadBoolBit()
+	if err != nil || !hasIn {
+		return res, nil, nil
+	}
+	in, err := io.LoadRefCell()
+	if err != nil {
+		return res, nil, nil
+	}
+	return res, in.Hash(), nil
+}
+
+// txResult extracts the phase outcomes and created message bodies of tx.
+func txResult(tx *tlb.Transaction, root *cell.Cell) *TxResult {
+	res := &TxResult{Hash: hex.EncodeToString(root.Hash()), LT: tx.LT}
+	if desc, ok := tx.Description.(tlb.TransactionDescriptionOrdinary); ok {
+		res.Aborted = desc.Aborted
+		if vm, ok := desc.ComputePhase.Phase.(tlb.ComputePhaseVM); ok {
+			res.ComputeExitCode = vm.Details.ExitCode
+		}
+		if desc.ActionPhase != nil {
+			res.ActionResultCode = desc.ActionPhase.ResultCode
+		}
+	}
+	if tx.IO.Out != nil {
+		if out, err := tx.IO.Out.ToSlice(); err == nil {
+			res.outBodies = make(map[string]bool, len(out))
+			for _, msg := range out {
+				if msg.MsgType == tlb.MsgTypeInternal {
+					res.outBodies[BodyHash(msg.AsInternal().Payload())] = true
+				}
+			}
+		}
+	}
+	return res
+}
+
+func parseTransaction(data string) (*tlb.Transaction, *cell.Cell, error) {
+	boc, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode transaction: %w", err)
+	}
+	root, err := cell.FromBOC(boc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode transaction: %w", err)
+	}
+	var tx tlb.Transaction
+	if err := tlb.LoadFromCell(&tx, root.BeginParse()); err != nil {
+		return nil, nil, fmt.Errorf("parse transaction: %w", err)
+	}
+	return &tx, root, nil
+}
+
+type tonTransactionsResponse struct {
+	Ok     bool                `json:"ok"`
+	Result []tonRawTransaction `json:"result"`
+	Error  string              `json:"error"`
+}
+
+type tonRawTransaction struct {
+	Utime         int64  `json:"utime"`
+	Data          string `json:"data"`
+	TransactionID struct {
+		LT   string `json:"lt"`
+		Hash string `json:"hash"`
+	} `json:"transaction_id"`
+}
@@ -0,0 +1,142 @@
+package ton
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/xssnick/tonutils-go/ton/wallet"
+)
+
+// WalletVersion is the contract a custodial wallet is deployed as. It is
+// stored per wallet row; empty means V4R2, the only version before W5.
+type WalletVersion string
+
+const (
+	WalletV4R2       WalletVersion = "v4r2"
+	WalletV5R1       WalletVersion = "v5r1"
+	WalletHighloadV3 WalletVersion = "highload_v3"
+)
+
+// ErrUnsupportedWalletVersion is returned for unknown wallet versions.
+var ErrUnsupportedWalletVersion = errors.New("ton client: unsupported wallet version")
+
+const (
+	// highloadTimeout is the highload v3 message lifetime. It is part of
+	// the contract data, so changing it changes every highload address.
+	highloadTimeout = uint32(MessageTTL / time.Second)
+	// highloadClockSkew backdates created_at so a node clock slightly
+	// behind ours does not reject the message as coming from the future.
+	highloadClockSkew = 30 * time.Second
+	highloadMaxQuery  = 1 << 23
+)
+
+// ParseWalletVersion normalizes a requested or stored wallet version.
+func ParseWalletVersion(raw string) (WalletVersion, error) {
+	switch v := WalletVersion(strings.ToLower(strings.TrimSpace(raw))); v {
+	case "":
+		return WalletV4R2, nil
+	case WalletV4R2, WalletV5R1, WalletHighloadV3:
+		return v, nil
+	case "w5", "w5r1", "v5":
+		return WalletV5R1, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedWalletVersion, raw)
+	}
+}
+
+// QueryIDSource hands out highload v3 query ids. An id must not repeat
+// within twice the message timeout, or the wallet rejects the message.
+type QueryIDSource func(ctx context.Context) (uint32, error)
+
+// localQueryIDs counts up from a random start. It is only unique within one
+// process; deployments with several instances should share a source.
+func localQueryIDs() QueryIDSource {
+	var seed [4]byte
+	_, _ = rand.Read(seed[:])
+	var next atomic.Uint32
+	next.Store(binary.BigEndian.Uint32(seed[:]))
+	return func(context.Context) (uint32, error) {
+		return next.Add(1) % highloadMaxQuery, nil
+	}
+}
+
+// versionConfig returns the tonutils wallet config for version.
+func versionConfig(version WalletVersion, queryIDs QueryIDSource) (wallet.VersionConfig, error) {
+	switch version {
+	case WalletV4R2, "":
+		return wallet.V4R2, nil
+	case WalletV5R1:
+		return wallet.ConfigV5R1Final{NetworkGlobalID: wallet.MainnetGlobalID}, nil
+	case WalletHighloadV3:
+		if queryIDs == nil {
+			queryIDs = localQueryIDs()
+		}
+		return wallet.ConfigHighloadV3{
+			MessageTTL: highloadTimeout,
+			MessageBuilder: func(ctx context.Context, _ uint32) (uint32, int64, error) {
+				id, err := queryIDs(ctx)
+				if err != nil {
+					return 0, 0, err
+				}
+				return id % highloadMaxQuery, time.Now().Add(-highloadClockSkew).Unix(), nil
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedWalletVersion, version)
+	}
+}
+
+// WalletFromMnemonic opens the wallet contract of the given version
+// controlled by mnemonic. queryIDs is only used by highload wallets; nil
+// falls back to a process-local counter.
+func WalletFromMnemonic(mnemonic string, version WalletVersion, queryIDs QueryIDSource) (*wallet.Wallet, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("mnemonic is required")
+	}
+	cfg, err := versionConfig(version, queryIDs)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := wallet.SeedToPrivateKey(words, "", false)
+	if err != nil {
+		return nil, fmt.Errorf("mnemonic decode failed: %w", err)
+	}
+	contract, err := wallet.FromPrivateKey(nil, priv, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init wallet: %w", err)
+	}
+	return contract, nil
+}
+
+// OpenWallet is WalletFromMnemonic with the client's highload query id source.
+func (c *Client) OpenWallet(mnemonic string, version WalletVersion) (*wallet.Wallet, error) {
+	return WalletFromMnemonic(mnemonic, version, c.queryIDs)
+}
+
+// MaxMessages is how many internal messages the relayer packs into one
+// external message of contract. Highload v3 wraps larger sets into a message
+// to itself, which hides per-message outcomes from the signing transaction,
+// so hot wallets send one message each and run orders in parallel instead.
+func MaxMessages(contract *wallet.Wallet) int {
+	switch contract.GetSpec().(type) {
+	case *wallet.SpecV5R1Final:
+		return 255
+	case *wallet.SpecHighloadV3:
+		return 1
+	default:
+		return 4
+	}
+}
+
+// seqnoSpec is implemented by the seqno-based wallet specs (V4R2, W5).
+type seqnoSpec interface {
+	SetSeqnoFetcher(fetcher func(ctx context.Context, subWallet uint32) (uint32, error))
+	SetMessagesTTL(ttl uint32)
+}
@@ -0,0 +1,61 @@
+package ton
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/ton/wallet"
+)
+
+func TestParseWalletVersion(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want WalletVersion
+	}{
+		{"", WalletV4R2},
+		{"v4r2", WalletV4R2},
+		{" V4R2 ", WalletV4R2},
+		{"v5r1", WalletV5R1},
+		{"w5", WalletV5R1},
+		{"W5R1", WalletV5R1},
+		{"v5", WalletV5R1},
+		{"highload_v3", WalletHighloadV3},
+	}
+	for _, tt := range tests {
+		got, err := ParseWalletVersion(tt.raw)
+		if err != nil {
+			t.Errorf("ParseWalletVersion(%q): %v", tt.raw, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseWalletVersion(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+	for _, raw := range []string{"v3r2", "highload", "v4r3"} {
+		if _, err := ParseWalletVersion(raw); !errors.Is(err, ErrUnsupportedWalletVersion) {
+			t.Errorf("ParseWalletVersion(%q) error = %v, want ErrUnsupportedWalletVersion", raw, err)
+		}
+	}
+}
+
+func TestMaxMessages(t *testing.T) {
+	mnemonic := strings.Join(wallet.NewSeed(), " ")
+	tests := []struct {
+		version WalletVersion
+		want    int
+	}{
+		{WalletV4R2, 4},
+		{WalletV5R1, 255},
+		{WalletHighloadV3, 1},
+	}
+	for _, tt := range tests {
+		contract, err := WalletFromMnemonic(mnemonic, tt.version, nil)
+		if err != nil {
+			t.Fatalf("WalletFromMnemonic(%s): %v", tt.version, err)
+		}
+		if got := MaxMessages(contract); got != tt.want {
+			t.Errorf("MaxMessages(%s) = %d, want %d", tt.version, got, tt.want)
+		}
+	}
+}